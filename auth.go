@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/distribution/reference"
+	registrytypes "github.com/docker/docker/api/types/registry"
+)
+
+// registryAuth returns the encoded RegistryAuth header for pulling img with
+// the credentials of the docker CLI config, or "" when there are none and the
+// daemon pulls anonymously.
+func registryAuth(img string) string {
+	named, err := reference.ParseNormalizedNamed(img)
+	if err != nil {
+		return ""
+	}
+	auth := configAuth(reference.Domain(named))
+	if auth == (registrytypes.AuthConfig{}) {
+		return ""
+	}
+	header, err := registrytypes.EncodeAuthConfig(auth)
+	if err != nil {
+		return ""
+	}
+	return header
+}
+
+// authPrompt asks the program to prompt for registry credentials on behalf of
+// pull idx, which the daemon refused as unauthorized.
+type authPrompt struct {
+	idx   int
+	image string
+	reply chan<- authReply
+}
+
+// authReply is the outcome of an authPrompt: an encoded RegistryAuth header,
+// or why there is none.
+type authReply struct {
+	header string
+	err    error
+}
+
+// privilegeFunc returns an image.PullOptions.PrivilegeFunc that has the
+// program prompt for credentials, as `docker pull` does for a first private
+// pull. The client calls it once and retries the pull with the answer.
+func privilegeFunc(idx int, img string, out chan<- tea.Msg) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		reply := make(chan authReply, 1)
+		out <- authPrompt{idx, img, reply}
+		select {
+		case r := <-reply:
+			return r.header, r.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// credentialPrompt reads a username and password on the program's terminal.
+// It runs through tea.Exec, which takes the terminal out of raw mode and
+// stops the renderer until it returns.
+type credentialPrompt struct {
+	authPrompt
+	in  io.Reader
+	out io.Writer
+}
+
+func (c *credentialPrompt) SetStdin(r io.Reader)  { c.in = r }
+func (c *credentialPrompt) SetStdout(w io.Writer) { c.out = w }
+func (c *credentialPrompt) SetStderr(io.Writer)   {}
+
+// Run prompts and always answers the waiting pull, with an error when no
+// credentials were given.
+func (c *credentialPrompt) Run() error {
+	header, err := c.prompt()
+	c.reply <- authReply{header, err}
+	return nil
+}
+
+func (c *credentialPrompt) prompt() (string, error) {
+	server := "docker.io"
+	if named, err := reference.ParseNormalizedNamed(c.image); err == nil {
+		server = reference.Domain(named)
+	}
+	in := bufio.NewReader(c.in)
+	fmt.Fprintf(c.out, "\nAuthentication required to pull %s from %s\nUsername: ", c.image, server)
+	user, err := in.ReadString('\n')
+	user = strings.TrimSpace(user)
+	if err != nil || user == "" {
+		return "", errors.New("unauthorized: no credentials given")
+	}
+	fmt.Fprint(c.out, "Password: ")
+	var password string
+	if f, ok := c.in.(interface{ Fd() uintptr }); ok && term.IsTerminal(f.Fd()) {
+		// the terminal is cooked again, so turn echo off just for this
+		b, err := term.ReadPassword(f.Fd())
+		fmt.Fprintln(c.out)
+		if err != nil {
+			return "", err
+		}
+		password = string(b)
+	} else {
+		line, _ := in.ReadString('\n')
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if server == "docker.io" {
+		// the key Docker Hub credentials are stored under
+		server = "https://index.docker.io/v1/"
+	}
+	return registrytypes.EncodeAuthConfig(registrytypes.AuthConfig{
+		Username:      user,
+		Password:      password,
+		ServerAddress: server,
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	registrytypes "github.com/docker/docker/api/types/registry"
+)
+
+// withConfig points --config-dir at a fresh directory holding config.
+func withConfig(t *testing.T, config string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	saved := configDir
+	configDir = dir
+	t.Cleanup(func() { configDir = saved })
+}
+
+func TestRegistryAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake credential helper is a shell script")
+	}
+	bin := t.TempDir()
+	helper := "#!/bin/sh\nread server\necho '{\"ServerURL\":\"'$server'\",\"Username\":\"ci\",\"Secret\":\"from-helper\"}'\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker-credential-fake"), []byte(helper), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	inline := base64.StdEncoding.EncodeToString([]byte("alice:s3cret"))
+	tests := []struct {
+		name   string
+		config string
+		image  string
+		want   registrytypes.AuthConfig
+	}{
+		{
+			name:   "inline for docker hub",
+			config: `{"auths":{"https://index.docker.io/v1/":{"auth":"` + inline + `"}}}`,
+			image:  "node:20",
+			want:   registrytypes.AuthConfig{Username: "alice", Password: "s3cret", ServerAddress: "https://index.docker.io/v1/"},
+		},
+		{
+			name:   "identity token",
+			config: `{"auths":{"ghcr.io":{"identitytoken":"tok"}}}`,
+			image:  "ghcr.io/org/app:1",
+			want:   registrytypes.AuthConfig{IdentityToken: "tok", ServerAddress: "ghcr.io"},
+		},
+		{
+			name:   "credential helper",
+			config: `{"credHelpers":{"registry.example.com":"fake"}}`,
+			image:  "registry.example.com/team/app",
+			want:   registrytypes.AuthConfig{Username: "ci", Password: "from-helper", ServerAddress: "registry.example.com"},
+		},
+		{
+			name:   "helper wins over inline",
+			config: `{"credsStore":"fake","auths":{"quay.io":{"auth":"` + inline + `"}}}`,
+			image:  "quay.io/org/app",
+			want:   registrytypes.AuthConfig{Username: "ci", Password: "from-helper", ServerAddress: "quay.io"},
+		},
+		{
+			name:   "missing helper falls back to inline",
+			config: `{"credsStore":"absent","auths":{"quay.io":{"auth":"` + inline + `"}}}`,
+			image:  "quay.io/org/app",
+			want:   registrytypes.AuthConfig{Username: "alice", Password: "s3cret", ServerAddress: "quay.io"},
+		},
+		{
+			name:   "not logged in",
+			config: `{"auths":{"quay.io":{"auth":"` + inline + `"}}}`,
+			image:  "node:20",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, tt.config)
+			header := registryAuth(tt.image)
+			if tt.want == (registrytypes.AuthConfig{}) {
+				if header != "" {
+					t.Errorf("registryAuth() = %q, want none", header)
+				}
+				return
+			}
+			b, err := base64.URLEncoding.DecodeString(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got registrytypes.AuthConfig
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("registryAuth() decodes to %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The --rawjson-fd stream mirrors BuildKit's SolveStatus, the same format
+// `docker buildx build --progress=rawjson` prints, so BuildKit-aware
+// consumers can render pull progress; --progress-socket carries the same
+// stream to a listening Unix socket. Each line is one JSON object:
+//
+//	{"vertexes":[{"digest":"sha256:…","name":"pull node:20","started":"…","completed":"…","cached":false,"error":""}],
+//	 "statuses":[{"id":"3f4ca61aafcd","vertex":"sha256:…","name":"Downloading","current":1024,"total":4096,"timestamp":"…","started":"…","completed":"…"}]}
+//
+// Every message also carries the run's --run-id as "runID".
+//
+// Each image is one vertex whose digest is derived from its ref; each layer is
+// a status of that vertex. Times are RFC 3339 and omitted until known.
+// With --rawjson-style=pretty each object is indented over several lines
+// instead.
+
+type solveStatus struct {
+	// RunID is not part of BuildKit's format; it tells the messages of
+	// concurrent runs apart for a collector reading them all
+	RunID    string         `json:"runID,omitempty"`
+	Vertexes []vertex       `json:"vertexes,omitempty"`
+	Statuses []vertexStatus `json:"statuses,omitempty"`
+}
+
+type vertex struct {
+	Digest    string     `json:"digest"`
+	Name      string     `json:"name,omitempty"`
+	Started   *time.Time `json:"started,omitempty"`
+	Completed *time.Time `json:"completed,omitempty"`
+	Cached    bool       `json:"cached,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type vertexStatus struct {
+	ID        string     `json:"id"`
+	Vertex    string     `json:"vertex,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Total     int64      `json:"total,omitempty"`
+	Current   int64      `json:"current"`
+	Timestamp time.Time  `json:"timestamp"`
+	Started   *time.Time `json:"started,omitempty"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// rawjsonWriter encodes pull progress as a stream of solveStatus messages.
+type rawjsonWriter struct {
+	enc *json.Encoder
+	// started records when each layer status was first seen
+	started map[string]time.Time
+	// compactStatus names layer statuses by compactLayerStatus
+	compactStatus bool
+	// runID is stamped on every message
+	runID string
+}
+
+// rawjson styles for --rawjson-style.
+const (
+	// rawjsonCompact writes one message per line, for piping to jq
+	rawjsonCompact = "compact"
+	// rawjsonPretty indents each message for reading
+	rawjsonPretty = "pretty"
+)
+
+func newRawjsonWriter(w io.Writer, style string) *rawjsonWriter {
+	enc := json.NewEncoder(w)
+	if style == rawjsonPretty {
+		enc.SetIndent("", "  ")
+	}
+	return &rawjsonWriter{enc: enc, started: map[string]time.Time{}}
+}
+
+// newRunID returns a random UUID (version 4) naming one invocation.
+func newRunID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func vertexDigest(image string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("pull "+image)))
+}
+
+// pullChanged reports the image-level state of p as its vertex.
+func (w *rawjsonWriter) pullChanged(p *imagePull) {
+	v := vertex{
+		Digest: vertexDigest(p.image),
+		Name:   "pull " + p.image,
+	}
+	if p.started {
+		v.Started = &p.startedAt
+	}
+	if p.finished() {
+		t := now()
+		v.Completed = &t
+		v.Cached = p.done && !p.sawDownload
+	}
+	switch {
+	case p.cancelReason != notCancelled:
+		v.Error = "context canceled: " + p.cancelReason.String()
+	case p.err != nil:
+		v.Error = p.err.Error()
+	}
+	_ = w.enc.Encode(solveStatus{RunID: w.runID, Vertexes: []vertex{v}})
+}
+
+// layerChanged reports the current state of one layer of p.
+func (w *rawjsonWriter) layerChanged(p *imagePull, id string) {
+	ls, ok := p.layers.get(id)
+	if !ok {
+		return
+	}
+	name := ls.status
+	if w.compactStatus {
+		name = compactLayerStatus(name, ls.phase)
+	}
+	at := now()
+	key := p.image + "/" + id
+	started, ok := w.started[key]
+	if !ok {
+		started = at
+		w.started[key] = started
+	}
+	s := vertexStatus{
+		ID:        id,
+		Vertex:    vertexDigest(p.image),
+		Name:      name,
+		Total:     ls.total,
+		Current:   ls.current,
+		Timestamp: at,
+		Started:   &started,
+	}
+	if ls.done {
+		s.Completed = &at
+	}
+	_ = w.enc.Encode(solveStatus{RunID: w.runID, Statuses: []vertexStatus{s}})
+}
+
+func (*rawjsonWriter) end([]*imagePull) {}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"dockerpulltui/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// cancelReason records what cancelled a run; the zero value means it was not
+// cancelled.
+type cancelReason int
+
+const (
+	notCancelled cancelReason = iota
+	// cancelUser is Esc or Ctrl-C pressed in the UI.
+	cancelUser
+	// cancelSignal is SIGINT delivered to the process.
+	cancelSignal
+	// cancelFailFast is another pull failing under --fail-fast.
+	cancelFailFast
+	// cancelOutput is the output going away, e.g. its terminal closing.
+	cancelOutput
+	// cancelTimeout is the run exceeding --max-runtime.
+	cancelTimeout
+)
+
+func (r cancelReason) String() string {
+	switch r {
+	case cancelUser:
+		return "user"
+	case cancelSignal:
+		return "signal"
+	case cancelFailFast:
+		return "fail-fast"
+	case cancelOutput:
+		return "output closed"
+	case cancelTimeout:
+		return "max-runtime"
+	}
+	return ""
+}
+
+// cancelMsg cancels every pull for the given reason.
+type cancelMsg struct{ reason cancelReason }
+
+// cancelFilter turns the component's ui.CancelMsg and SIGINT into a
+// cancelMsg with the matching reason, so runs without keyboard input still end
+// cleanly.
+func cancelFilter(_ tea.Model, msg tea.Msg) tea.Msg {
+	switch msg.(type) {
+	case tea.InterruptMsg:
+		return cancelMsg{cancelSignal}
+	case ui.CancelMsg:
+		return cancelMsg{cancelUser}
+	}
+	return msg
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// concurrency tracks how many layers download at once, sampled on every
+// progress event, which costs a few additions.
+type concurrency struct {
+	peak int
+	// sum and samples average the count over the samples taken while any
+	// layer was downloading, so extraction does not dilute it
+	sum, samples int
+}
+
+func (c *concurrency) sample(downloading int) {
+	if downloading == 0 {
+		return
+	}
+	c.peak = max(c.peak, downloading)
+	c.sum += downloading
+	c.samples++
+}
+
+func (c concurrency) average() float64 {
+	if c.samples == 0 {
+		return 0
+	}
+	return float64(c.sum) / float64(c.samples)
+}
+
+func (c concurrency) String() string {
+	return fmt.Sprintf("peak %d layers downloading at once, %.1f on average", c.peak, c.average())
+}
+
+// printConcurrencyReport tells how many layers each image downloaded at once,
+// and for a batch how many the daemon had in flight across all of them. A
+// peak stuck at the daemon's max-concurrent-downloads (3 by default) hints
+// that this limit, not the network, bounds the pull.
+func printConcurrencyReport(w io.Writer, m model) {
+	for _, p := range m.pulls {
+		if p.concurrency.samples > 0 {
+			fmt.Fprintf(w, "%s: %s\n", p.image, p.concurrency)
+		}
+	}
+	if m.isBatch() && m.concurrency.samples > 0 {
+		fmt.Fprintf(w, "all images: %s\n", m.concurrency)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"dockerpulltui/pull"
+)
+
+// detailKey toggles between each pull's single line and the per-layer view.
+const detailKey = "d"
+
+// layerLines renders one indented line per layer of a running pull for the
+// detailed view: its ID, status and, while it moves bytes, its progress and,
+// with --eta, its speed and time left.
+// Ended pulls and pulls that show no bar have none.
+func (p *imagePull) layerLines(opts options) []string {
+	if p.finished() || p.quiet(opts) || !p.barVisible(opts) {
+		return nil
+	}
+	entries := p.layers.snapshot()
+	lines := make([]string, 0, len(entries))
+	for _, l := range entries {
+		bytes, remaining := "", int64(0)
+		switch {
+		case l.phase == pull.PhaseExtracting && l.extractTotal > 0:
+			bytes = fmt.Sprintf("%s/%s", opts.bytes(l.extractCurrent), opts.bytes(l.extractTotal))
+			remaining = l.extractTotal - l.extractCurrent
+		case l.phase == pull.PhaseDownloading && l.total > 0:
+			bytes = fmt.Sprintf("%s/%s", opts.bytes(l.current), opts.bytes(l.total))
+			remaining = l.total - l.current
+		}
+		if opts.eta && l.rate.bps > 0 {
+			// without a known size there is a speed but no ETA
+			bytes += fmt.Sprintf("  %s/s", opts.bytes(int64(l.rate.bps)))
+			if eta := l.rate.eta(remaining); eta > 0 {
+				bytes += " ETA " + humanDuration(eta)
+			}
+		}
+		line := fmt.Sprintf("    %-12s  %-18s  %s", l.id, opts.layerStatus(l.layerState), bytes)
+		lines = append(lines, strings.TrimRight(line, " ")+"\n")
+	}
+	return lines
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// contextMeta is the part of a docker CLI context we need, as stored in
+// <config>/contexts/meta/<sha256(name)>/meta.json.
+type contextMeta struct {
+	Name      string
+	Endpoints map[string]struct {
+		Host          string
+		SkipTLSVerify bool
+	}
+}
+
+// configDir is set by --config-dir, which like `docker --config` takes
+// precedence over $DOCKER_CONFIG.
+var configDir string
+
+// dockerConfigDir returns the docker CLI config directory: --config-dir,
+// $DOCKER_CONFIG or ~/.docker.
+func dockerConfigDir() string {
+	if configDir != "" {
+		return configDir
+	}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// currentContext returns the context the docker CLI would use when none is
+// given explicitly. An empty result means the environment decides.
+func currentContext() string {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return ""
+	}
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name
+	}
+	b, err := os.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if err != nil {
+		return ""
+	}
+	var cfg struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if json.Unmarshal(b, &cfg) != nil {
+		return ""
+	}
+	return cfg.CurrentContext
+}
+
+// clientOptions returns the options for a daemon client. An sshHost takes
+// precedence; otherwise the docker context called name is honored, or the
+// active context when name is empty. Without a context the client is
+// configured from the environment.
+func clientOptions(name, sshHost string) ([]client.Opt, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if sshHost != "" {
+		if !strings.HasPrefix(sshHost, "ssh://") {
+			sshHost = "ssh://" + sshHost
+		}
+		return withHost(opts, sshHost)
+	}
+	if name == "" {
+		name = currentContext()
+	}
+	if name == "" || name == "default" {
+		if host := os.Getenv(client.EnvOverrideHost); strings.HasPrefix(host, "ssh://") {
+			return withHost(opts, host)
+		}
+		return opts, nil
+	}
+	id := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+	b, err := os.ReadFile(filepath.Join(dockerConfigDir(), "contexts", "meta", id, "meta.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("docker context %q not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta contextMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return nil, fmt.Errorf("docker context %q: %w", name, err)
+	}
+	ep, ok := meta.Endpoints["docker"]
+	if !ok || ep.Host == "" {
+		return nil, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+	if strings.HasPrefix(ep.Host, "tcp://") {
+		tlsDir := filepath.Join(dockerConfigDir(), "contexts", "tls", id, "docker")
+		httpClient, err := contextHTTPClient(tlsDir, ep.SkipTLSVerify)
+		if err != nil {
+			return nil, fmt.Errorf("docker context %q: %w", name, err)
+		}
+		if httpClient != nil {
+			// must precede WithHost so the host's dialer is applied to it
+			opts = append(opts, client.WithHTTPClient(httpClient))
+		}
+	}
+	return withHost(opts, ep.Host)
+}
+
+// withHost appends the option to reach host, tunnelling ssh:// hosts.
+func withHost(opts []client.Opt, host string) ([]client.Opt, error) {
+	if strings.HasPrefix(host, "ssh://") {
+		sshOpts, err := sshClientOptions(host)
+		if err != nil {
+			return nil, err
+		}
+		return append(opts, sshOpts...), nil
+	}
+	return append(opts, client.WithHost(host)), nil
+}
+
+// contextHTTPClient builds a TLS-enabled HTTP client from the ca.pem,
+// cert.pem and key.pem stored for a context. It returns nil when the context
+// has no TLS material and does not skip verification.
+func contextHTTPClient(dir string, skipVerify bool) (*http.Client, error) {
+	cfg := &tls.Config{InsecureSkipVerify: skipVerify}
+	useTLS := skipVerify
+	if ca, err := os.ReadFile(filepath.Join(dir, "ca.pem")); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("invalid ca.pem")
+		}
+		cfg.RootCAs = pool
+		useTLS = true
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+		useTLS = true
+	}
+	if !useTLS {
+		return nil, nil
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}, nil
+}
@@ -0,0 +1,143 @@
+// Command buildcontext builds an image with the daemon's classic builder while
+// showing a ProgressLine for the upload of the build context, which docker
+// otherwise sends without any progress. It is an example of ui.Reader outside
+// of a Bubble Tea program.
+//
+//	go run ./examples/buildcontext DIR TAG
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"dockerpulltui/pull"
+	"dockerpulltui/ui"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// tarSize returns the size of the tar stream writeTar produces for dir: a
+// header block per entry, file contents padded to whole blocks, and two
+// zero blocks at the end. Names too long for a plain header add a little
+// more, which only makes the bar stop short of the end.
+func tarSize(dir string) (int64, error) {
+	const block = 512
+	size := int64(2 * block)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		size += block
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += (info.Size() + block - 1) / block * block
+		}
+		return nil
+	})
+	return size, err
+}
+
+// writeTar writes dir as a tar stream to w, with paths relative to dir.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if d.Type()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// build sends dir to the daemon as the build context of an image tagged tag.
+func build(ctx context.Context, dir, tag string) error {
+	total, err := tarSize(dir)
+	if err != nil {
+		return err
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTar(pw, dir))
+	}()
+	pl := ui.NewProgressLine(fmt.Sprintf("Sending build context %s", dir))
+	body := ui.NewReader(pr, total, pl)
+	body.Out = os.Stderr
+	restore := ui.PrepareTerminal(os.Stderr)
+	defer restore()
+	resp, err := cli.ImageBuild(ctx, body, types.ImageBuildOptions{Tags: []string{tag}, Remove: true})
+	restore()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// the build output shares the pull stream's framing and error
+	// messages; its step output comes in "stream" fields
+	printStream := func(msg map[string]any) bool {
+		if s, ok := msg["stream"].(string); ok {
+			fmt.Print(s)
+		}
+		return true
+	}
+	return pull.Decode(ctx, resp.Body, []pull.Filter{printStream}, func(pull.Event) {})
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: buildcontext DIR TAG")
+		os.Exit(2)
+	}
+	if err := build(context.Background(), os.Args[1], os.Args[2]); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+// Command copyfile copies a file while showing a ProgressLine, as an example
+// of using the component outside of docker pulls.
+//
+//	go run ./examples/copyfile SRC DST
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"dockerpulltui/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type copyDone struct{ err error }
+
+type model struct {
+	pl        *ui.ProgressLine
+	err       error
+	cancelled bool
+}
+
+func (m model) Init() tea.Cmd {
+	return m.pl.InitCmd()
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		cmd, _ := m.pl.Update(msg)
+		return m, cmd
+	case ui.CancelMsg:
+		m.cancelled = true
+		return m, tea.Quit
+	case copyDone:
+		m.err = msg.err
+		if msg.err == nil {
+			_, _ = m.pl.Update(ui.DoneMsg{})
+		}
+		return m, tea.Quit
+	}
+	cmd, _ := m.pl.Update(msg)
+	return m, cmd
+}
+
+func (m model) View() string {
+	switch {
+	case m.cancelled:
+		return fmt.Sprintf("%s...CANCELLED\n", m.pl.Label)
+	case m.err != nil:
+		return fmt.Sprintf("%s...FAILED\n", m.pl.Label)
+	case m.pl.Done:
+		return fmt.Sprintf("%s...DONE\n", m.pl.Label)
+	}
+	return m.pl.View() + "\n"
+}
+
+// copyFile copies src to dst, reporting progress to p.
+func copyFile(p *tea.Program, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, ui.NewCountingReader(in, info.Size(), p.Send)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: copyfile SRC DST")
+		os.Exit(2)
+	}
+	src, dst := os.Args[1], os.Args[2]
+	p := tea.NewProgram(model{pl: ui.NewProgressLine(fmt.Sprintf("Copying %s", src))})
+	go func() {
+		p.Send(copyDone{copyFile(p, src, dst)})
+	}()
+	final, err := p.Run()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if err := final.(model).err; err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// patternList is a repeatable flag of glob patterns.
+type patternList []string
+
+func (l *patternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *patternList) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q", pattern)
+	}
+	*l = append(*l, pattern)
+	return nil
+}
+
+// excludeImages splits images into those to pull and those matching any of
+// patterns. Patterns are globs as in path.Match, where * stops at a slash,
+// matched against each ref as written.
+func excludeImages(images []string, patterns patternList) (keep, excluded []string) {
+	for _, img := range images {
+		if matchesAny(img, patterns) {
+			excluded = append(excluded, img)
+		} else {
+			keep = append(keep, img)
+		}
+	}
+	return keep, excluded
+}
+
+func matchesAny(img string, patterns patternList) bool {
+	for _, p := range patterns {
+		// patterns were validated by Set
+		if ok, _ := path.Match(p, img); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// reportExcluded lists the images --exclude skipped.
+func reportExcluded(w io.Writer, excluded []string) {
+	for _, img := range excluded {
+		fmt.Fprintf(w, "Skipped %s: excluded\n", img)
+	}
+}
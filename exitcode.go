@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"dockerpulltui/pull"
+)
+
+// Exit codes, so wrapper scripts can branch on why a pull failed without
+// parsing messages.
+const (
+	exitOK = 0
+	// exitFailure is any failure without a more specific code, including
+	// invalid flags.
+	exitFailure   = 1
+	exitAuth      = 10
+	exitNotFound  = 11
+	exitRateLimit = 12
+	exitNetwork   = 13
+	exitTimeout   = 14
+	exitCancelled = 15
+)
+
+// quietErrors is set by --quiet-errors: errors go without their "Error: "
+// prefix and hints, for callers that rely on the exit status.
+var quietErrors bool
+
+// errorPrefix is what error messages start with.
+func errorPrefix() string {
+	if quietErrors {
+		return ""
+	}
+	return "Error: "
+}
+
+// errorf writes an error message to stderr, away from piped results.
+func errorf(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, errorPrefix()+format+"\n", a...)
+}
+
+// fatalf reports an error and exits with exitFailure.
+func fatalf(format string, a ...any) {
+	errorf(format, a...)
+	os.Exit(exitFailure)
+}
+
+// exitCodeFor returns the exit code for a pull error of kind k.
+func exitCodeFor(k pull.Kind) int {
+	switch k {
+	case pull.KindAuth:
+		return exitAuth
+	case pull.KindNotFound:
+		return exitNotFound
+	case pull.KindRateLimit:
+		return exitRateLimit
+	case pull.KindNetwork:
+		return exitNetwork
+	}
+	return exitFailure
+}
+
+// exitCode returns the exit code of a run: exitOK when every pull succeeded,
+// otherwise the code of the first failure, and exitCancelled when pulls were
+// only cancelled.
+func exitCode(pulls []*imagePull) int {
+	code := exitOK
+	for _, p := range pulls {
+		if p.cancelReason == cancelTimeout {
+			return exitTimeout
+		}
+		if p.err != nil {
+			return exitCodeFor(pull.Classify(p.err))
+		}
+		if p.cancelReason != notCancelled {
+			code = exitCancelled
+		}
+	}
+	return code
+}
+
+// usage prints the flag help followed by the exit codes.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [image ...]\n\nFlags:\n", flag.CommandLine.Name())
+	flag.PrintDefaults()
+	fmt.Fprintf(out, `
+Exit status:
+  %d   every pull succeeded
+  %d   a pull failed for another reason, or the flags are invalid
+  %d  the registry refused access
+  %d  the image, tag or manifest does not exist
+  %d  the registry rate limited the pull
+  %d  the daemon or registry could not be reached
+  %d  the run exceeded --max-runtime
+  %d  the run was cancelled, e.g. with Ctrl-C
+When several pulls fail, the first failure decides.
+`, exitOK, exitFailure, exitAuth, exitNotFound, exitRateLimit, exitNetwork, exitTimeout, exitCancelled)
+}
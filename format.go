@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// humanBytesBase formats n with two decimals in units of base: 1000 gives the
+// decimal units docker images prints, e.g. 5.49MB or 999B and 1.00kB; any
+// other base gives 1024-based units, e.g. 5.23MB.
+func humanBytesBase(n int64, base int) string {
+	return formatBytesWith(fmt.Sprintf, n, 2, base)
+}
+
+// Units of formatBytesWith by base.
+var (
+	binaryUnits  = []string{"KB", "MB", "GB", "TB"}
+	decimalUnits = []string{"kB", "MB", "GB", "TB"}
+)
+
+// formatBytesWith formats n in units of base, 1000 or else 1024, with
+// precision decimals, printing the number through sprintf, e.g. a locale's
+// message.Printer. A negative precision is narrower: one decimal below 10 of
+// a unit, none above, and whole values without decimals, e.g. 5.2MB, 523MB,
+// 1GB.
+func formatBytesWith(sprintf func(string, ...any) string, n int64, precision, base int) string {
+	unit, units := 1024.0, binaryUnits
+	if base == 1000 {
+		unit, units = 1000, decimalUnits
+	}
+	if float64(n) < unit {
+		return sprintf("%dB", n)
+	}
+	v := float64(n)
+	i := -1
+	for v >= unit && i < len(units)-1 {
+		v /= unit
+		i++
+	}
+	if precision < 0 {
+		switch {
+		case v == float64(int64(v)) || v >= 10:
+			precision = 0
+		default:
+			precision = 1
+		}
+	}
+	return sprintf("%.*f%s", precision, v, units[i])
+}
+
+// shortID truncates an image ID to the 12 hex digits docker shows.
+func shortID(id string) string {
+	if len(id) > 7 && id[:7] == "sha256:" {
+		id = id[7:]
+	}
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+// humanDuration formats d to the second in the style of humanBytesBase, e.g.
+// 45s, 3m12s or 1h04m; from an hour on, seconds are dropped.
+func humanDuration(d time.Duration) string {
+	secs := max(int64(d/time.Second), 0)
+	h, m, sec := secs/3600, secs/60%60, secs%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, sec)
+	}
+	return fmt.Sprintf("%ds", sec)
+}
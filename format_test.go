@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHumanBytesBase(t *testing.T) {
+	tests := []struct {
+		n    int64
+		base int
+		want string
+	}{
+		{999, 1000, "999B"},
+		{1000, 1000, "1.00kB"},
+		{1023, 1000, "1.02kB"},
+		{1024, 1000, "1.02kB"},
+		{999, 1024, "999B"},
+		{1000, 1024, "1000B"},
+		{1023, 1024, "1023B"},
+		{1024, 1024, "1.00KB"},
+		{5_490_000, 1000, "5.49MB"},
+		{5_484_052, 1024, "5.23MB"},
+	}
+	for _, tt := range tests {
+		if got := humanBytesBase(tt.n, tt.base); got != tt.want {
+			t.Errorf("humanBytesBase(%d, %d) = %q, want %q", tt.n, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytesCompact(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{1023, "1023B"},
+		{1024, "1KB"},
+		{5_452_595, "5.2MB"},
+		{548_405_248, "523MB"},
+		{1 << 30, "1GB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytesWith(fmt.Sprintf, tt.n, -1, 1024); got != tt.want {
+			t.Errorf("formatBytesWith(%d, -1) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{-time.Second, "0s"},
+		{45*time.Second + 900*time.Millisecond, "45s"},
+		{3*time.Minute + 12*time.Second, "3m12s"},
+		{time.Hour + 4*time.Minute + 59*time.Second, "1h04m"},
+	}
+	for _, tt := range tests {
+		if got := humanDuration(tt.d); got != tt.want {
+			t.Errorf("humanDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// glyphSet holds every character beyond plain text that the renderers draw,
+// so that --ascii-only switches all of them at once.
+type glyphSet struct {
+	// barFull and barEmpty fill the progress bar
+	barFull, barEmpty rune
+	// spark are the sparkline's levels, lowest first
+	spark []rune
+	// ellipsis ends a truncated label
+	ellipsis string
+	// up, down and sep make up the key help
+	up, down, sep string
+}
+
+var (
+	unicodeGlyphs = glyphSet{
+		barFull:  '█',
+		barEmpty: '░',
+		spark:    []rune("▁▂▃▄▅▆▇█"),
+		ellipsis: "…",
+		up:       "↑",
+		down:     "↓",
+		sep:      " • ",
+	}
+	asciiGlyphs = glyphSet{
+		barFull:  '#',
+		barEmpty: '-',
+		spark:    []rune("_.-=+*#"),
+		ellipsis: "...",
+		up:       "up",
+		down:     "down",
+		sep:      " | ",
+	}
+)
+
+// glyphsFor returns the ASCII glyphs when ascii is set, else the Unicode ones.
+func glyphsFor(ascii bool) glyphSet {
+	if ascii {
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
+}
+
+// unicodeTerminal reports whether the environment's character set is UTF-8
+// and the terminal is capable enough to draw block glyphs.
+func unicodeTerminal() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
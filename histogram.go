@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// histogramTop is how many of the largest layers --histogram shows.
+	histogramTop = 10
+	// histogramWidth is the width of the largest layer's bar.
+	histogramWidth = 30
+)
+
+// asciiBar renders frac of width columns as #, padded with spaces.
+func asciiBar(frac float64, width int) string {
+	n := min(max(int(frac*float64(width)+0.5), 0), width)
+	return strings.Repeat("#", n) + strings.Repeat(" ", width-n)
+}
+
+// printHistogram writes a bar chart of p's largest downloaded layers, scaled
+// to the largest one. Layers the pull did not download report no size and are
+// left out.
+func printHistogram(w io.Writer, p *imagePull, opts options) {
+	var layers []layerEntry
+	for _, l := range p.layers.snapshot() {
+		if l.total > 0 {
+			layers = append(layers, l)
+		}
+	}
+	if len(layers) == 0 {
+		fmt.Fprintf(w, "%s: no layer sizes reported\n", p.image)
+		return
+	}
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].total > layers[j].total
+	})
+	fmt.Fprintf(w, "%s: largest layers\n", p.image)
+	largest := float64(layers[0].total)
+	for _, l := range layers[:min(len(layers), histogramTop)] {
+		fmt.Fprintf(w, "  %s  %s  %s\n", shortID(l.id), asciiBar(float64(l.total)/largest, histogramWidth), opts.bytes(l.total))
+	}
+	if more := len(layers) - histogramTop; more > 0 {
+		fmt.Fprintf(w, "  (%d more)\n", more)
+	}
+}
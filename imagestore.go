@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+)
+
+// snapshotterDriverType is the DriverStatus driver-type of a daemon that
+// keeps images in the containerd image store rather than a graph driver.
+const snapshotterDriverType = "io.containerd.snapshotter.v1"
+
+// describeImageStore tells where the daemon described by info keeps images:
+// the graph driver or containerd snapshotter and namespace, whether it runs
+// rootless, and its data root.
+func describeImageStore(info system.Info) string {
+	containerd := slices.Contains(info.DriverStatus, [2]string{"driver-type", snapshotterDriverType})
+	var parts []string
+	switch {
+	case containerd && info.Containerd != nil && info.Containerd.Namespaces.Containers != "":
+		parts = append(parts, fmt.Sprintf("containerd (snapshotter %s, namespace %s)", info.Driver, info.Containerd.Namespaces.Containers))
+	case containerd:
+		parts = append(parts, fmt.Sprintf("containerd (snapshotter %s)", info.Driver))
+	default:
+		parts = append(parts, "graph driver "+info.Driver)
+	}
+	if slices.Contains(info.SecurityOptions, "name=rootless") {
+		parts = append(parts, "rootless")
+	}
+	if info.DockerRootDir != "" {
+		parts = append(parts, "at "+info.DockerRootDir)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printImageStore reports the image store that received the pulled images.
+// The daemon API cannot direct a pull to a store of its own choosing, so this
+// only confirms where they landed, e.g. on rootless or multi-store setups.
+func printImageStore(w io.Writer, pulls []*imagePull, opts options) {
+	if !slices.ContainsFunc(pulls, func(p *imagePull) bool { return p.done && !p.present }) {
+		return
+	}
+	cli, err := client.NewClientWithOpts(opts.clientOpts...)
+	if err != nil {
+		errorf("%v", err)
+		return
+	}
+	defer cli.Close()
+	info, err := cli.Info(context.Background())
+	if err != nil {
+		errorf("%v", err)
+		return
+	}
+	fmt.Fprintf(w, "Image store: %s\n", describeImageStore(info))
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"sync"
+
+	"dockerpulltui/pull"
+)
+
+// layerSet holds the per-layer state of a pull in the order layers first
+// appeared. It is safe for concurrent use, so readers outside the program's
+// update loop always see whole layer states.
+type layerSet struct {
+	mu    sync.RWMutex
+	byID  map[string]layerState
+	order []string
+	// sums is kept current on every update, so the per-event progress
+	// computation does not walk images with hundreds of layers
+	sums layerTotals
+}
+
+// layerTotals sums the layers of a set. Byte counts only include layers whose
+// size is known.
+type layerTotals struct {
+	current, total int64
+	// transferred excludes the bytes of resumed downloads
+	transferred int64
+	// moved is transferred counting layers of unknown size too, for the
+	// transfer rate
+	moved int64
+	// extracted is the byte size of the extracted part of each layer
+	extracted int64
+	// complete counts layers that are fully pulled
+	complete int
+	// downloaded counts layers that finished downloading
+	downloaded int
+	// downloading counts layers whose download is in flight
+	downloading int
+	// existing counts layers the daemon already had
+	existing int
+}
+
+// add adds ls to t, or removes it when sign is -1.
+func (t *layerTotals) add(ls layerState, sign int64) {
+	if ls.complete() {
+		t.complete += int(sign)
+	}
+	if ls.phase == pull.PhaseExists {
+		t.existing += int(sign)
+	}
+	if ls.done {
+		t.downloaded += int(sign)
+	} else if ls.phase == pull.PhaseDownloading {
+		t.downloading += int(sign)
+	}
+	t.moved += sign * (ls.current - ls.baseline)
+	if ls.total <= 0 {
+		return
+	}
+	t.current += sign * ls.current
+	t.total += sign * ls.total
+	t.transferred += sign * (ls.current - ls.baseline)
+	t.extracted += sign * int64(float64(ls.total)*ls.extracted())
+}
+
+// layerEntry is one layer of a snapshot.
+type layerEntry struct {
+	id string
+	layerState
+}
+
+func newLayerSet() *layerSet {
+	return &layerSet{byID: map[string]layerState{}}
+}
+
+// update applies fn to the state of layer id, adding the layer if it is new.
+func (s *layerSet) update(id string, fn func(*layerState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, ok := s.byID[id]
+	if ok {
+		s.sums.add(ls, -1)
+	} else {
+		s.order = append(s.order, id)
+	}
+	fn(&ls)
+	s.byID[id] = ls
+	s.sums.add(ls, 1)
+}
+
+// updateEach applies fn to the state of every layer.
+func (s *layerSet) updateEach(fn func(*layerState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.order {
+		ls := s.byID[id]
+		s.sums.add(ls, -1)
+		fn(&ls)
+		s.byID[id] = ls
+		s.sums.add(ls, 1)
+	}
+}
+
+// expect records the size of layer id as given by the image manifest, for a
+// total known before the stream reports one. The stream's own total wins.
+func (s *layerSet) expect(id string, total int64) {
+	s.update(id, func(ls *layerState) {
+		if ls.total == 0 {
+			ls.total = total
+		}
+	})
+}
+
+// get returns the state of layer id, and whether it has been seen.
+func (s *layerSet) get(id string) (layerState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ls, ok := s.byID[id]
+	return ls, ok
+}
+
+// snapshot returns a copy of every layer in order.
+func (s *layerSet) snapshot() []layerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]layerEntry, len(s.order))
+	for i, id := range s.order {
+		out[i] = layerEntry{id, s.byID[id]}
+	}
+	return out
+}
+
+// totals returns the sums over every layer.
+func (s *layerSet) totals() layerTotals {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sums
+}
+
+// len returns the number of layers seen.
+func (s *layerSet) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.order)
+}
+
+// creditLayers returns the bytes each pull transferred, counting a layer that
+// several pulls share only for the first of them. Stream IDs are prefixes of
+// the layers' content digests, so a base layer common to several images has
+// the same ID in each of their streams while the daemon downloads it once.
+func creditLayers(pulls []*imagePull) map[*imagePull]int64 {
+	seen := map[string]bool{}
+	credit := map[*imagePull]int64{}
+	for _, p := range pulls {
+		for _, l := range p.layers.snapshot() {
+			if seen[l.id] {
+				continue
+			}
+			seen[l.id] = true
+			if l.total > 0 {
+				credit[p] += l.current - l.baseline
+			}
+		}
+	}
+	return credit
+}
+
+// mergedFraction returns the fraction of the bytes of the started pulls'
+// layers that are present, counting a layer shared between pulls once as
+// creditLayers does. The layers of a finished pull count as present, and
+// pulls not yet started keep the fraction below 1. ok is false while no layer
+// size is known.
+func mergedFraction(pulls []*imagePull) (frac float64, ok bool) {
+	seen := map[string]bool{}
+	var current, total int64
+	started := 0
+	for _, p := range pulls {
+		if !p.started {
+			continue
+		}
+		started++
+		for _, l := range p.layers.snapshot() {
+			if seen[l.id] || l.total <= 0 {
+				continue
+			}
+			seen[l.id] = true
+			total += l.total
+			if p.finished() {
+				current += l.total
+			} else {
+				current += min(l.current, l.total)
+			}
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(current) / float64(total) * float64(started) / float64(len(pulls)), true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestLayerSetConcurrent applies events while other goroutines read, as the
+// sinks and the render loop do; run it with -race. Readers must only ever see
+// whole layer states, and the running sums must match the layers at the end.
+func TestLayerSetConcurrent(t *testing.T) {
+	const layers, steps = 8, 200
+	const total = steps << 10
+	s := newLayerSet()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, l := range s.snapshot() {
+					if l.current > l.total {
+						t.Errorf("layer %s: current %d beyond total %d", l.id, l.current, l.total)
+						return
+					}
+				}
+				if sums := s.totals(); sums.current > sums.total {
+					t.Errorf("sums: current %d beyond total %d", sums.current, sums.total)
+					return
+				}
+				s.get("layer0")
+				s.len()
+			}
+		}()
+	}
+	for i := 1; i <= steps; i++ {
+		for l := 0; l < layers; l++ {
+			ev := pull.Event{ID: fmt.Sprintf("layer%d", l), Status: "Downloading", Current: int64(i) << 10, Total: total, HasCounts: true}
+			s.update(ev.ID, func(ls *layerState) { ls.apply(ev, pull.DockerPhases.Classify(ev.Status)) })
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	var want layerTotals
+	for _, l := range s.snapshot() {
+		want.add(l.layerState, 1)
+	}
+	if got := s.totals(); got != want {
+		t.Errorf("totals() = %+v, want %+v", got, want)
+	}
+	if got := s.totals().current; got != layers*total {
+		t.Errorf("current = %d, want %d", got, layers*total)
+	}
+}
+
+// BenchmarkLayerEvents feeds a synthetic stream of a 500-layer image through
+// the model, the per-event hot path that the running sums keep from walking
+// every layer.
+func BenchmarkLayerEvents(b *testing.B) {
+	const layers = 500
+	events := make([]progressEvent, 0, 4*layers)
+	for step := int64(1); step <= 4; step++ {
+		for l := 0; l < layers; l++ {
+			events = append(events, progressEvent{Event: pull.Event{
+				ID: fmt.Sprintf("%012x", l), Status: "Downloading",
+				Current: step << 20, Total: 4 << 20, HasCounts: true,
+			}})
+		}
+	}
+	opts := options{eta: true, smoothing: defaultSmoothing}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		var m tea.Model = startedModel([]string{"huge:latest"}, opts)
+		b.StartTimer()
+		for _, ev := range events {
+			m, _ = m.Update(ev)
+		}
+	}
+}
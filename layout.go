@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// lineElement is an optional part of a running pull's line after its bar,
+// such as the rate or the elapsed timer.
+type lineElement struct {
+	text string
+	// drop ranks the element for --compact-when-narrow; the lowest goes
+	// first when the line does not fit
+	drop int
+}
+
+// Drop order of the line elements, first dropped first.
+const (
+	dropRate = iota
+	dropSparkline
+	dropLayers
+	dropPhase
+	dropTimer
+)
+
+// percentWidth is the width of the percentage the bar ends with, below which
+// it cannot shrink.
+const percentWidth = len(" 100%")
+
+// joinElements renders elements in order.
+func joinElements(elements []lineElement) string {
+	var b strings.Builder
+	for _, e := range elements {
+		b.WriteString(e.text)
+	}
+	return b.String()
+}
+
+// layoutLine composes p's bar line and elements, given in display order, to
+// fit cols terminal cells. While the line is too wide it drops elements by
+// rank, then shrinks the bar down to its percentage, and at last truncates
+// the label, so the line never wraps.
+func (p *imagePull) layoutLine(cols int, elements []lineElement) string {
+	pl := *p.pl
+	label := pl.LabelWidth
+	if label == 0 {
+		label = runewidth.StringWidth(pl.Label)
+	}
+	width := label + len("... ") + pl.Bar.Width
+	for _, e := range elements {
+		width += runewidth.StringWidth(e.text)
+	}
+	elements = append([]lineElement(nil), elements...)
+	for width > cols && len(elements) > 0 {
+		first := 0
+		for i, e := range elements {
+			if e.drop < elements[first].drop {
+				first = i
+			}
+		}
+		width -= runewidth.StringWidth(elements[first].text)
+		elements = append(elements[:first], elements[first+1:]...)
+	}
+	if over := width - cols; over > 0 {
+		shrink := min(over, max(pl.Bar.Width-percentWidth, 0))
+		pl.Bar.Width -= shrink
+		width -= shrink
+	}
+	if over := width - cols; over > 0 {
+		pl.LabelWidth = max(label-over, 1)
+	}
+	return pl.View() + joinElements(elements)
+}
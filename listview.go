@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listChrome is the number of rows below the list: the footer and the help.
+// Once quitting the help row is dropped.
+const listChrome = 2
+
+// isBatch reports whether the run uses the scrollable list view.
+func (m model) isBatch() bool {
+	return len(m.pulls) > 1
+}
+
+// scroll handles list navigation keys, reporting whether msg was one.
+func (m *model) scroll(msg tea.KeyMsg) bool {
+	page := m.listHeight()
+	switch msg.String() {
+	case "up", "k":
+		m.offset--
+	case "down", "j":
+		m.offset++
+	case "pgup":
+		m.offset -= page
+	case "pgdown", " ":
+		m.offset += page
+	case "home", "g":
+		m.offset = 0
+	case "end", "G":
+		m.offset = len(m.rows())
+	default:
+		return false
+	}
+	m.clampOffset()
+	return true
+}
+
+// batchPrefix returns the position of the i-th pull in a batch, e.g. "[ 2/15] ",
+// padded so the rows stay aligned, or "" outside batch mode.
+func (m model) batchPrefix(i int) string {
+	if !m.isBatch() {
+		return ""
+	}
+	n := len(m.pulls)
+	return fmt.Sprintf("[%*d/%d] ", len(strconv.Itoa(n)), i+1, n)
+}
+
+// listHeight returns how many list rows fit on screen. Before the terminal
+// size is known every row is shown.
+func (m model) listHeight() int {
+	if m.height == 0 {
+		return len(m.rows())
+	}
+	return max(m.height-listChrome, 1)
+}
+
+func (m *model) clampOffset() {
+	m.offset = max(min(m.offset, len(m.rows())-m.listHeight()), 0)
+}
+
+// rows renders the list rows: one per started pull and, in the detailed
+// view, one per layer of each running pull below it.
+func (m model) rows() []string {
+	var rows []string
+	for i, p := range m.pulls {
+		if !p.started {
+			continue
+		}
+		row := p.View(m.opts)
+		if row != "" {
+			// quiet pulls render nothing, not even their position
+			row = m.batchPrefix(i) + row
+		}
+		rows = append(rows, row)
+		if m.detailed {
+			rows = append(rows, p.layerLines(m.opts)...)
+		}
+	}
+	return rows
+}
+
+// overall returns how many pulls have ended and how many have not started,
+// and the run's overall completion in [0,1]: the mean of the pulls' progress,
+// or with mergeLayers the share of the layers' bytes present.
+func (m model) overall() (done, waiting int, frac float64) {
+	var sum float64
+	for _, p := range m.pulls {
+		switch {
+		case !p.started:
+			waiting++
+		case p.finished():
+			done++
+			sum++
+		default:
+			sum += p.pl.Percent
+		}
+	}
+	if m.opts.mergeLayers {
+		if f, ok := mergedFraction(m.pulls); ok {
+			return done, waiting, f
+		}
+	}
+	return done, waiting, sum / float64(len(m.pulls))
+}
+
+// listView renders started pulls as a scrollable list, followed by an
+// overall footer and the key help.
+func (m model) listView() string {
+	rows := m.rows()
+	done, waiting, frac := m.overall()
+	end := min(m.offset+m.listHeight(), len(rows))
+	var b strings.Builder
+	for _, r := range rows[min(m.offset, end):end] {
+		b.WriteString(r)
+	}
+	b.WriteString(m.opts.sprintf("%d/%d finished, %.0f%% overall", done, len(m.pulls), 100*frac))
+	switch {
+	case waiting > 0 && m.cancelReason == notCancelled:
+		b.WriteString(m.opts.sprintf(", %d waiting", waiting))
+	case waiting > 0:
+		// cancelled before they started, e.g. by --fail-fast
+		b.WriteString(m.opts.sprintf(", %d skipped", waiting))
+	}
+	if hidden := len(rows) - (end - m.offset); hidden > 0 {
+		b.WriteString(m.opts.sprintf(" (%d more)", hidden))
+	}
+	b.WriteString("\n")
+	if !m.quitting {
+		g := m.opts.glyphs
+		b.WriteString(strings.Join([]string{g.up + "/" + g.down + " scroll", "pgup/pgdn page", detailKey + " layers", "esc cancel all"}, g.sep) + "\n")
+	}
+	return b.String()
+}
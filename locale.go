@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// localeAuto makes --locale follow LC_ALL, LC_NUMERIC or LANG.
+const localeAuto = "auto"
+
+// localePrinter returns the printer for --locale name, or nil for the plain
+// formatting: when name is empty, or "auto" finds no usable environment
+// locale.
+func localePrinter(name string) (*message.Printer, error) {
+	if name == localeAuto {
+		name = envLocale()
+	}
+	if name == "" {
+		return nil, nil
+	}
+	tag, err := language.Parse(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locale %q: %w", name, err)
+	}
+	return message.NewPrinter(tag), nil
+}
+
+// envLocale returns the numeric locale of the environment as a BCP 47 tag,
+// e.g. de-DE for LANG=de_DE.UTF-8, or "" for the C locale.
+func envLocale() string {
+	var v string
+	for _, key := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if v = os.Getenv(key); v != "" {
+			break
+		}
+	}
+	v, _, _ = strings.Cut(v, ".")
+	v, _, _ = strings.Cut(v, "@")
+	if v == "C" || v == "POSIX" {
+		return ""
+	}
+	return strings.ReplaceAll(v, "_", "-")
+}
@@ -1,257 +1,594 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
-	"dockerpulltui/ui"
+	"dockerpulltui/pull"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/client"
+	"github.com/charmbracelet/x/term"
 )
 
-type progressEvent struct {
-	id      string
-	status  string
-	current int64
-	total   int64
-}
-
-type pullDone struct{}
-
-type pullErr struct{ err error }
-
-type layerState struct {
-	current int64
-	total   int64
-	status  string
-	done    bool
-}
-
-type model struct {
-	image     string
-	layers    map[string]layerState
-	order     []string
-	pl        *ui.ProgressLine
-	ctx       context.Context
-	cancel    context.CancelFunc
-	msgCh     chan tea.Msg
-	cancelled bool
-	done      bool
-	// hideBar avoids showing the bar for up-to-date pulls
-	hideBar     bool
-	sawDownload bool
+// readImageFile returns the image refs listed in path, one per line. Blank
+// lines and lines starting with # are ignored.
+func readImageFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var images []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		images = append(images, line)
+	}
+	return images, sc.Err()
 }
 
-func initialModel(image string) model {
-	ctx, cancel := context.WithCancel(context.Background())
-	label := fmt.Sprintf("Pulling %s", image)
-	return model{
-		image:  image,
-		layers: map[string]layerState{},
-		order:  []string{},
-		pl:     ui.NewProgressLine(label),
-		ctx:    ctx,
-		cancel: cancel,
-		msgCh:  make(chan tea.Msg, 256),
+// printPulledRefs prints the digest-pinned reference of every successful pull
+// whose digest was reported.
+func printPulledRefs(pulls []*imagePull) {
+	for _, p := range pulls {
+		if !p.done || p.cancelReason != notCancelled || p.digest == "" {
+			continue
+		}
+		ref, err := pulledRef(p.image, p.digest)
+		if err != nil {
+			errorf("%s: %v", p.image, err)
+			continue
+		}
+		fmt.Println(ref)
 	}
 }
 
-func (m model) Init() tea.Cmd {
-	go pullImage(m.ctx, m.image, m.msgCh)
-	return tea.Batch(waitForMsg(m.msgCh), m.pl.InitCmd())
+// printImageIDs prints the ID of each image that was pulled or found present,
+// or the manifest digest when the image could not be inspected.
+func printImageIDs(pulls []*imagePull) {
+	for _, p := range pulls {
+		if !p.done || p.cancelReason != notCancelled {
+			continue
+		}
+		switch {
+		case p.imageID != "":
+			fmt.Println(p.imageID)
+		case p.digest != "":
+			fmt.Println(p.digest)
+		}
+	}
 }
 
-func waitForMsg(ch <-chan tea.Msg) tea.Cmd {
-	return func() tea.Msg {
-		msg, ok := <-ch
-		if !ok {
-			return pullDone{}
+// reportErrors writes the error of each failed pull to w, with a hint on how to
+// resolve it when the failure is recognized.
+func reportErrors(w io.Writer, pulls []*imagePull) {
+	for _, p := range pulls {
+		if p.tagErr != nil {
+			fmt.Fprintf(w, "%stagging %s: %v\n", errorPrefix(), p.image, p.tagErr)
+		}
+		if p.err == nil {
+			continue
+		}
+		if len(pulls) > 1 {
+			fmt.Fprintf(w, "%s%s: %v\n", errorPrefix(), p.image, p.err)
+		} else {
+			fmt.Fprintf(w, "%s%v\n", errorPrefix(), p.err)
+		}
+		if hint := pull.Classify(p.err).Hint(); hint != "" && !quietErrors {
+			fmt.Fprintf(w, "Hint: %s\n", hint)
 		}
-		return msg
 	}
 }
 
-func pullImage(ctx context.Context, img string, out chan<- tea.Msg) {
-	defer close(out)
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		out <- pullErr{err}
-		return
+// reportLeftovers tells what cancelled pulls may have left in Docker's
+// storage. The daemon discards partial downloads itself and has no API to
+// drop the layers of one cancelled pull, so this only points at pruning.
+func reportLeftovers(w io.Writer, pulls []*imagePull) {
+	for _, p := range pulls {
+		if p.cancelReason != notCancelled && p.sawProgress {
+			fmt.Fprintln(w, "Note: cancelled pulls may leave downloaded layers in Docker's storage; `docker image prune` reclaims dangling data")
+			return
+		}
 	}
-	defer cli.Close()
+}
 
-	var opts image.PullOptions
-	rc, err := cli.ImagePull(ctx, img, opts)
-	if err != nil {
-		out <- pullErr{err}
-		return
-	}
-	defer rc.Close()
+// renderConfig says where and how runPulls renders progress.
+type renderConfig struct {
+	out *os.File
+	// mode is the --progress mode
+	mode            string
+	noCursorControl bool
+	// noRaw leaves the terminal in cooked mode by not reading the keyboard
+	noRaw bool
+	// timestamps prefixes the lines of the line-based modes with the time
+	timestamps bool
+	// titleProgress mirrors the progress in the window title; tty mode only
+	titleProgress bool
+	// noProgress renders nothing, for --format json where out carries the
+	// events instead
+	noProgress bool
+}
 
-	dec := json.NewDecoder(rc)
-	for {
-		var e map[string]any
-		if err := dec.Decode(&e); err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			if errors.Is(err, context.Canceled) || (err != nil && strings.Contains(strings.ToLower(err.Error()), "context canceled")) {
-				return
-			}
-			out <- pullErr{err}
-			return
+// runPulls runs the pulls of images to the end, rendering progress as rc
+// says, and returns the final model.
+func runPulls(images []string, opts options, rc renderConfig) model {
+	tty := !rc.noProgress && (rc.mode == progressTTY || (rc.mode == progressAuto && useANSI(rc.out)))
+	// credentials can only be asked for on a terminal the program reads
+	opts.promptAuth = tty && !rc.noRaw
+	var tm tea.Model = initialModel(images, opts)
+	progOpts := []tea.ProgramOption{tea.WithFilter(cancelFilter), tea.WithOutput(rc.out)}
+	if tty {
+		if rc.noCursorControl {
+			progOpts = append(progOpts, tea.WithOutput(cursorFilter{rc.out}))
 		}
-		if errStr, ok := e["error"].(string); ok && errStr != "" {
-			out <- pullErr{errors.New(errStr)}
-			return
+		if rc.titleProgress {
+			tm = titleModel{model: tm.(model)}
+			fmt.Fprint(rc.out, pushTitleSeq)
+			defer fmt.Fprint(rc.out, popTitleSeq)
 		}
-		id := ""
-		if s, ok := e["id"].(string); ok {
-			id = s
+	} else {
+		var out io.Writer = rc.out
+		if rc.noProgress {
+			out = io.Discard
 		}
-		status := ""
-		if s, ok := e["status"].(string); ok {
-			status = s
+		pm := newPlainModel(tm.(model), out, rc.mode == progressCR)
+		pm.timestamps = rc.timestamps
+		tm = pm
+		progOpts = append(progOpts, tea.WithoutRenderer())
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			// no keyboard to read; SIGINT still cancels
+			progOpts = append(progOpts, tea.WithInput(nil))
 		}
-		var current, total int64
-		if pd, ok := e["progressDetail"].(map[string]any); ok {
-			if c, ok := pd["current"].(float64); ok {
-				current = int64(c)
-			}
-			if t, ok := pd["total"].(float64); ok {
-				total = int64(t)
+	}
+	if rc.noRaw {
+		// without input the terminal stays cooked and Ctrl-C sends SIGINT
+		progOpts = append(progOpts, tea.WithInput(nil))
+	}
+	p := tea.NewProgram(tm, progOpts...)
+	if opts.maxRuntime > 0 {
+		defer startWatchdog(p, opts.maxRuntime)()
+	}
+	final, err := p.Run()
+	killed := errors.Is(err, tea.ErrProgramKilled) && opts.maxRuntime > 0
+	if err != nil && !killed {
+		fatalf("%v", err)
+	}
+	switch fm := final.(type) {
+	case plainModel:
+		final = fm.model
+	case titleModel:
+		final = fm.model
+	}
+	if killed {
+		// only the watchdog kills the program; the pulls it could not wind
+		// down count as timed out, and the caller still ends the sinks
+		errorf("exceeded --max-runtime of %s", opts.maxRuntime)
+		m := final.(model)
+		m.cancelAll(cancelTimeout)
+		for _, p := range m.pulls {
+			if p.endedAt.IsZero() {
+				p.done = true
+				p.endedAt = now()
+				m.opts.sinks.pullChanged(p)
 			}
 		}
-		out <- progressEvent{id: id, status: status, current: current, total: total}
+		final = m
 	}
-	out <- pullDone{}
+	if c := final.(model).crashed; c != nil {
+		// the terminal is restored by now; the pull failed, but the stack
+		// is what a bug report needs, under a visible cursor
+		if tty && !rc.noCursorControl {
+			rc.out.Write(showCursorSeq)
+		}
+		fmt.Fprintf(os.Stderr, "panic in pull: %v\n\n%s", c.value, c.stack)
+	}
+	return final.(model)
+}
+
+// outputImageID is the --output value that prints only image IDs.
+const outputImageID = "image-id"
+
+// Values of --format.
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+func main() {
+	os.Exit(realMain())
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		// Let component swallow keys; it emits ui.CancelMsg on Esc/Ctrl-C
-		if cmd, handled := m.pl.Update(msg); handled {
-			return m, cmd
+// realMain runs the tool and returns its exit code. Deferred closes run
+// before main exits, so files such as --json are complete when it does.
+func realMain() int {
+	file := flag.String("file", "", "read image refs from `path`, one per line")
+	fromCompose := flag.String("from-compose", "", "pull the images of the services in the Compose file at `path`")
+	fromK8s := flag.String("from-k8s", "", "pull the container images of the Kubernetes manifest at `path`")
+	parallel := flag.Int("parallel", 3, "pull at most `N` images concurrently (0 = no limit)")
+	progressFD := flag.Int("progress-fd", -1, "write progress to file descriptor `N` instead of stdout")
+	compactStatus := flag.Bool("compact-status", false, "collapse transient layer statuses such as Waiting into \"preparing\" in the layer view, --plain-layers and --rawjson-fd output")
+	rawjsonFD := flag.Int("rawjson-fd", -1, "also write BuildKit rawjson progress to file descriptor `N`")
+	progressSocket := flag.String("progress-socket", "", "also stream rawjson progress to the Unix socket listening at `path`")
+	runID := flag.String("run-id", "", "identify this run as `id` in every --rawjson-fd, --progress-socket, --json and --format json message and in --summary (default: a random UUID)")
+	jsonPath := flag.String("json", "", "also write each image's progress as newline-delimited JSON events to `file`")
+	summaryPath := flag.String("summary", "", "once all pulls end, write the results of the run as JSON to `file`")
+	rawjsonStyle := flag.String("rawjson-style", rawjsonCompact, "--rawjson-fd and --progress-socket message layout: compact (one per line) or pretty (indented)")
+	noRaw := flag.Bool("no-raw", false, "leave the terminal out of raw mode; keys are not read and Ctrl-C cancels by signal")
+	bell := flag.Bool("bell", false, "ring the terminal bell when the pulls complete or fail")
+	notifyEnd := flag.Bool("notify", false, "show a desktop notification when the pulls complete or fail, where the OS has a notifier")
+	timestamps := flag.Bool("timestamps", false, "prefix each progress line with an ISO 8601 timestamp; applies to line-based output only, not the in-place bar")
+	titleProgress := flag.Bool("title-progress", false, "also show the overall percentage in the terminal window title, restoring the previous title on exit where the terminal supports it")
+	noCursorControl := flag.Bool("no-cursor-control", false, "never hide or show the terminal cursor")
+	fromStdin := flag.Bool("from-stdin", false, "render a captured pull stream, plain or gzip-compressed, read from stdin instead of pulling")
+	simulate := flag.Bool("simulate", false, "render a synthetic pull instead of pulling, for demos and testing; needs no daemon")
+	simulateLayers := flag.Int("simulate-layers", 5, "number of layers in each --simulate pull")
+	simulateSize := flag.String("simulate-size", "200MB", "total `size` of each --simulate pull")
+	simulateSpeed := flag.String("simulate-speed", "20MB", "download `rate` per second of each --simulate pull")
+	replaySpeed := flag.String("replay-speed", "", "pace --from-stdin replay at `speed` (e.g. 1x, 2x, realtime)")
+	sparklineFlag := flag.Bool("sparkline", false, "show a sparkline of recent throughput next to the bar")
+	asciiOnly := flag.Bool("ascii-only", !unicodeTerminal(), "draw bars, sparklines and key help with ASCII characters only; on by default without a UTF-8 locale")
+	elapsed := flag.Bool("elapsed", false, "show a running elapsed timer while pulling")
+	eta := flag.Bool("eta", false, "show the transfer rate and estimated time left, and each layer's in the layer view")
+	smoothing := flag.Float64("smoothing", defaultSmoothing, "rate smoothing `factor` in (0,1]; higher reacts faster")
+	quietOnUpToDate := flag.Bool("quiet-on-uptodate", false, "print nothing when the image is already up to date")
+	finalizeOn := flag.String("finalize-on", finalizeExtract, "when a pull counts as complete: extract (pulled and extracted) or download (all layers downloaded; frees its --parallel slot early, though the tool still waits for extraction before exiting)")
+	checksumProgress := flag.Bool("checksum-progress", false, "show the verify/extract phase once downloads complete")
+	labelWidth := flag.Int("label-width", 0, "pad or truncate labels to `N` columns so bars align (0 = longest label)")
+	since := flag.Duration("since", 0, "hold back the bar until bytes move or `duration` has passed")
+	extractWeight := flag.Float64("extract-weight", 0.2, "share of the bar in [0,1) given to extracting layers; the rest is downloading")
+	output := flag.String("output", "", "set to image-id to print only the ID of each resulting image to stdout; progress goes to stderr")
+	format := flag.String("format", formatText, "write progress as `format`: text, drawn as bars, or json, one JSON event per line on stdout in place of the bars")
+	printRef := flag.Bool("print-pulled-ref", false, "print the digest-pinned reference of each pulled image to stdout; progress goes to stderr")
+	prefetch := flag.Bool("prefetch-manifest", false, "fetch each image's manifest from the registry before pulling, so the bar knows every layer's size from the first byte; costs a round trip per image")
+	cacheBreakdown := flag.Bool("cache-breakdown", false, "end each pull's line with the bytes downloaded and the bytes the daemon already had, e.g. \"downloaded 40MB, reused 80MB\"")
+	heartbeat := flag.Bool("heartbeat", false, "animate a marker after a bar that has not moved for a while, to show the pull is waiting rather than hung")
+	plainLayers := flag.Bool("plain-layers", false, "in plain and cr progress modes, also print docker's own line for each layer, e.g. \"3f4ca61aafcd: Downloading [==>   ] 1.2MB/4.1MB\"")
+	compactWhenNarrow := flag.Bool("compact-when-narrow", false, "on a narrow terminal, drop the rate, then the other details, then shrink the bar so a pull's line never wraps")
+	compact := flag.Bool("compact-bytes", false, "print byte counts with fewer decimals, e.g. 5.2MB")
+	siBytes := flag.Bool("si-bytes", false, "print byte counts in decimal units of 1000, e.g. 5.49MB, as docker images does")
+	tag := flag.String("tag", "", "tag the pulled image locally as `name:tag`")
+	var status statusText
+	flag.StringVar(&status.Done, "status-done", defaultStatusText.Done, "final `text` for a completed pull")
+	flag.StringVar(&status.UpToDate, "status-uptodate", defaultStatusText.UpToDate, "final `text` for an image that was already up to date")
+	flag.StringVar(&status.Present, "status-present", defaultStatusText.Present, "final `text` for a pull skipped by --pull-policy")
+	flag.StringVar(&status.Cancelled, "status-cancelled", defaultStatusText.Cancelled, "final `text` for a cancelled pull")
+	flag.StringVar(&status.Failed, "status-failed", defaultStatusText.Failed, "final `text` for a failed pull")
+	flag.StringVar(&configDir, "config-dir", "", "docker CLI config `dir` for credentials and contexts, like docker --config (default: $DOCKER_CONFIG or ~/.docker)")
+	dockerContext := flag.String("context", "", "docker context `name` to use (default: the active context)")
+	sshHost := flag.String("ssh", "", "reach the daemon over ssh at `[user@]host[:port]`")
+	failFast := flag.Bool("fail-fast", false, "cancel the remaining pulls as soon as one fails")
+	keepGoing := flag.Bool("keep-going", false, "attempt every pull even after failures (the default)")
+	respectRateLimit := flag.Bool("respect-rate-limit", false, "when the registry rate limits a pull, wait out the cooldown and retry")
+	cleanOnCancel := flag.Bool("clean-on-cancel", false, "on cancel, report the layers each pull completed and how to reclaim leftover data")
+	concurrencyReport := flag.Bool("concurrency-report", false, "once all pulls end, print the peak and average number of layers that downloaded at once")
+	histogram := flag.Bool("histogram", false, "chart the largest layers of each pulled image once all pulls end")
+	reportFormat := flag.String("report-format", "", "once all pulls end, print a table of results in `format`: text, or md for Markdown")
+	groupByRegistry := flag.Bool("group-by-registry", false, "print images, bytes and rate per registry once all pulls end")
+	platformReport := flag.Bool("image-platform-report", false, "print the platform of each pulled image, warning when it differs from the daemon's and will run emulated")
+	storeReport := flag.Bool("image-store-report", false, "print which daemon image store received the pulled images: graph driver or containerd snapshotter and namespace, rootless, data root")
+	mergeLayers := flag.Bool("merge-layers-by-digest", false, "count layers shared between the pulled images once in overall progress and in --group-by-registry and --summary totals")
+	minBarWidth := flag.Int("min-bar-width", 10, "never shrink the bar below `N` columns")
+	maxBarWidth := flag.Int("max-bar-width", 80, "never grow the bar beyond `N` columns")
+	locale := flag.String("locale", "", "format numbers for locale `tag`, e.g. de-DE, or auto to follow LC_ALL/LC_NUMERIC/LANG")
+	maxRuntime := flag.Duration("max-runtime", 0, "cancel the pulls once the run has taken `duration`, killing it outright should they not stop, and exit with status 14")
+	watch := flag.Duration("watch", 0, "pull again every `interval` until interrupted, logging whether new content arrived")
+	progressMode := flag.String("progress", progressAuto, "progress output: auto, tty (redraw in place), plain (one line per change) or cr (carriage returns only, for pagers)")
+	noDaemon := flag.Bool("no-daemon", false, "only print each image's layer count and download size, asking the registry instead of the daemon")
+	pullPolicy := flag.String("pull-policy", pullAlways, "when to pull: always, missing (only if not present locally) or never")
+	var exclude patternList
+	flag.Var(&exclude, "exclude", "skip images whose ref matches glob `pattern`; may be repeated")
+	flag.BoolVar(&quietErrors, "quiet-errors", false, "print errors without the \"Error:\" prefix or hints, leaving the exit status to tell them apart")
+	flag.Usage = usage
+	flag.Parse()
+
+	var images []string
+	if *file != "" {
+		refs, err := readImageFile(*file)
+		if err != nil {
+			fatalf("%v", err)
 		}
-		return m, nil
-	case ui.CancelMsg:
-		m.cancelled = true
-		if m.cancel != nil {
-			m.cancel()
+		images = append(images, refs...)
+	}
+	var found []manifestImages
+	for _, path := range []string{*fromCompose, *fromK8s} {
+		if path == "" {
+			continue
 		}
-		return m, nil
-	case progressEvent:
-		lowerStatus := strings.ToLower(msg.status)
-		if strings.Contains(lowerStatus, "pulling from") {
-			// ignore top-level header
-			return m, waitForMsg(m.msgCh)
+		m, err := readManifestImages(path)
+		if err != nil {
+			fatalf("%v", err)
 		}
-		if strings.Contains(lowerStatus, "image is up to date") {
-			m.hideBar = true
+		found = append(found, m)
+		images = append(images, m.images...)
+	}
+	images = append(images, flag.Args()...)
+	if len(images) == 0 && len(found) > 0 {
+		reportFound(os.Stderr, found)
+		return exitOK
+	}
+	if len(images) == 0 {
+		images = []string{"node:20"}
+	}
+	if !*fromStdin {
+		// with --from-stdin the ref only labels the stream
+		invalid := false
+		for _, img := range images {
+			if err := validateRef(img); err != nil {
+				errorf("%v", err)
+				invalid = true
+			}
 		}
-		if strings.Contains(lowerStatus, "download") || strings.Contains(lowerStatus, "extract") {
-			m.sawDownload = true
+		if invalid {
+			return exitFailure
 		}
-		if msg.id != "" {
-			ls := m.layers[msg.id]
-			if _, ok := m.layers[msg.id]; !ok {
-				m.order = append(m.order, msg.id)
-			}
-			if msg.total > 0 {
-				ls.total = msg.total
-			}
-			if msg.current > 0 || msg.total == 0 {
-				ls.current = msg.current
-			}
-			if msg.status != "" {
-				ls.status = msg.status
-			}
-			switch msg.status {
-			case "Download complete", "Pull complete", "Already exists":
-				ls.done = true
-				if ls.total > 0 && ls.current < ls.total {
-					ls.current = ls.total
-				}
-			}
-			m.layers[msg.id] = ls
+	}
+	images, excluded := excludeImages(images, exclude)
+	if *parallel < 0 {
+		fatalf("--parallel must not be negative")
+	}
+	if *extractWeight < 0 || *extractWeight >= 1 {
+		fatalf("--extract-weight must be in [0,1)")
+	}
+	if *minBarWidth < 1 || *maxBarWidth < *minBarWidth {
+		fatalf("--min-bar-width must be positive and at most --max-bar-width")
+	}
+	if *labelWidth < 0 {
+		fatalf("--label-width must not be negative")
+	}
+	speed, err := parseReplaySpeed(*replaySpeed)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	switch *pullPolicy {
+	case pullAlways, pullMissing, pullNever:
+	default:
+		fatalf("invalid pull policy %q (want always, missing or never)", *pullPolicy)
+	}
+	if *tag != "" && len(images) > 1 {
+		fatalf("--tag needs exactly one image")
+	}
+	switch *progressMode {
+	case progressAuto, progressTTY, progressPlain, progressCR:
+	default:
+		fatalf("invalid progress mode %q (want auto, tty, plain or cr)", *progressMode)
+	}
+	switch {
+	case *output != "" && *output != outputImageID:
+		fatalf("invalid output %q (want %s)", *output, outputImageID)
+	case *output != "" && *printRef:
+		fatalf("--output and --print-pulled-ref both claim stdout")
+	case *format != formatText && *format != formatJSON:
+		fatalf("invalid format %q (want %s or %s)", *format, formatText, formatJSON)
+	case *format == formatJSON && (*output != "" || *printRef):
+		fatalf("--format json, --output and --print-pulled-ref all claim stdout")
+	}
+	if *rawjsonStyle != rawjsonCompact && *rawjsonStyle != rawjsonPretty {
+		fatalf("invalid rawjson style %q (want %s or %s)", *rawjsonStyle, rawjsonCompact, rawjsonPretty)
+	}
+	if *finalizeOn != finalizeExtract && *finalizeOn != finalizeDownload {
+		fatalf("invalid --finalize-on %q (want %s or %s)", *finalizeOn, finalizeExtract, finalizeDownload)
+	}
+	switch *reportFormat {
+	case "", reportText, reportMarkdown:
+	default:
+		fatalf("invalid report format %q (want %s or %s)", *reportFormat, reportText, reportMarkdown)
+	}
+	if *failFast && *keepGoing {
+		fatalf("--fail-fast and --keep-going are mutually exclusive")
+	}
+	if *watch < 0 || (*watch > 0 && *fromStdin) {
+		fatalf("--watch needs a positive interval and cannot be combined with --from-stdin")
+	}
+	if *noDaemon && *fromStdin {
+		fatalf("--no-daemon cannot be combined with --from-stdin")
+	}
+	if *simulate && (*fromStdin || *noDaemon || *watch > 0) {
+		fatalf("--simulate cannot be combined with --from-stdin, --no-daemon or --watch")
+	}
+	var sim *simulation
+	if *simulate {
+		if sim, err = parseSimulation(*simulateLayers, *simulateSize, *simulateSpeed); err != nil {
+			fatalf("%v", err)
 		}
-		// compute overall
-		var sumCurrent, sumTotal int64
-		allDone := true
-		for _, id := range m.order {
-			ls := m.layers[id]
-			if !(ls.status == "Pull complete" || ls.status == "Already exists") {
-				allDone = false
-			}
-			if ls.total > 0 {
-				sumCurrent += ls.current
-				sumTotal += ls.total
-			}
+	}
+	if *fromStdin && len(images) > 1 {
+		// a single stream can only describe one image; the ref is just its label
+		images = images[:1]
+	}
+	if *maxRuntime < 0 {
+		fatalf("--max-runtime must not be negative")
+	}
+	if *smoothing <= 0 || *smoothing > 1 {
+		fatalf("--smoothing must be in (0,1]")
+	}
+	printer, err := localePrinter(*locale)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if configDir != "" {
+		if fi, err := os.Stat(configDir); err != nil || !fi.IsDir() {
+			fatalf("--config-dir %s is not a directory", configDir)
 		}
-		// If all done and we never downloaded anything, hide the bar entirely
-		if allDone && !m.sawDownload {
-			m.hideBar = true
+	}
+	clientOpts, err := clientOptions(*dockerContext, *sshHost)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	opts := options{
+		parallel:          *parallel,
+		fromStdin:         *fromStdin,
+		simulate:          sim,
+		replaySpeed:       speed,
+		pullPolicy:        *pullPolicy,
+		elapsed:           *elapsed,
+		clientOpts:        clientOpts,
+		eta:               *eta,
+		smoothing:         *smoothing,
+		quietOnUpToDate:   *quietOnUpToDate,
+		checksumProgress:  *checksumProgress,
+		finalizeOn:        *finalizeOn,
+		mergeLayers:       *mergeLayers,
+		platformReport:    *platformReport,
+		sparkline:         *sparklineFlag,
+		glyphs:            glyphsFor(*asciiOnly),
+		labelWidth:        *labelWidth,
+		since:             *since,
+		status:            status,
+		extractWeight:     *extractWeight,
+		compactBytes:      *compact,
+		siBytes:           *siBytes,
+		compactStatus:     *compactStatus,
+		compactWhenNarrow: *compactWhenNarrow,
+		plainLayers:       *plainLayers,
+		heartbeat:         *heartbeat,
+		cacheBreakdown:    *cacheBreakdown,
+		maxRuntime:        *maxRuntime,
+		prefetchManifest:  *prefetch,
+		tag:               *tag,
+		failFast:          *failFast,
+		minBarWidth:       *minBarWidth,
+		maxBarWidth:       *maxBarWidth,
+		printer:           printer,
+		cleanOnCancel:     *cleanOnCancel,
+		respectRateLimit:  *respectRateLimit,
+	}
+	if *noDaemon {
+		err := printImageSizes(context.Background(), images, opts)
+		reportExcluded(os.Stdout, excluded)
+		if err != nil {
+			return exitCodeFor(pull.Classify(err))
 		}
-		var cmds []tea.Cmd
-		if sumTotal > 0 {
-			pct := float64(sumCurrent) / float64(sumTotal)
-			if !allDone && pct >= 0.999 {
-				pct = 0.99
-			}
-			if pct < m.pl.Percent {
-				pct = m.pl.Percent
-			}
-			if c, handled := m.pl.Update(ui.SetPercentMsg{Pct: pct}); handled {
-				cmds = append(cmds, c)
-			}
+		return exitOK
+	}
+	var rawjsonOut []io.Writer
+	if *rawjsonFD >= 0 {
+		f := os.NewFile(uintptr(*rawjsonFD), "rawjson")
+		if _, err := f.Stat(); err != nil {
+			fatalf("invalid file descriptor %d: %v", *rawjsonFD, err)
 		}
-		return m, tea.Batch(append(cmds, waitForMsg(m.msgCh))...)
-	case pullDone:
-		m.done = true
-		_, _ = m.pl.Update(ui.DoneMsg{})
-		return m, tea.Quit
-	case pullErr:
-		// Treat context canceled as clean exit
-		if errors.Is(msg.err, context.Canceled) || (msg.err != nil && strings.Contains(strings.ToLower(msg.err.Error()), "context canceled")) {
-			return m, tea.Quit
+		defer f.Close()
+		rawjsonOut = append(rawjsonOut, f)
+	}
+	if *progressSocket != "" {
+		// the socket is a side channel; without it the pull goes on
+		if sock, err := dialProgressSocket(*progressSocket); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: progress socket: %v\n", err)
+		} else {
+			defer sock.Close()
+			rawjsonOut = append(rawjsonOut, sock)
 		}
-		// Print error and quit
-		fmt.Printf("Error: %v\n", msg.err)
-		return m, tea.Quit
 	}
-	return m, nil
-}
-
-func (m model) View() string {
-	if m.cancelled {
-		return fmt.Sprintf("Pulling %s...CANCELLED\n", m.image)
+	if *runID == "" {
+		*runID = newRunID()
 	}
-	if m.done {
-		return fmt.Sprintf("Pulling %s...DONE\n", m.image)
+	if len(rawjsonOut) > 0 {
+		rawjson := newRawjsonWriter(io.MultiWriter(rawjsonOut...), *rawjsonStyle)
+		rawjson.compactStatus = *compactStatus
+		rawjson.runID = *runID
+		opts.sinks = append(opts.sinks, rawjson)
 	}
-	// If we haven't seen any downloading/extracting yet, or we've determined the image is up to date, hide the bar
-	if m.hideBar || !m.sawDownload {
-		return fmt.Sprintf("Pulling %s...\n", m.image)
+	if *jsonPath != "" {
+		f, err := os.Create(*jsonPath)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		defer f.Close()
+		opts.sinks = append(opts.sinks, newJSONSink(f, *runID))
+	}
+	if *format == formatJSON {
+		opts.sinks = append(opts.sinks, newJSONSink(os.Stdout, *runID))
+	}
+	if *summaryPath != "" {
+		opts.sinks = append(opts.sinks, summarySink{path: *summaryPath, runID: *runID, mergeLayers: *mergeLayers})
 	}
-	return m.pl.View()
-}
 
-func main() {
-	image := "node:20"
-	if len(os.Args) > 1 && strings.TrimSpace(os.Args[1]) != "" {
-		image = os.Args[1]
+	// stdout is kept for results when asked for; progress moves to stderr
+	uiOut, errOut := os.Stdout, os.Stderr
+	if *printRef || *output != "" || *format == formatJSON {
+		uiOut = os.Stderr
+	}
+	if *progressFD >= 0 {
+		f := os.NewFile(uintptr(*progressFD), "progress")
+		// an empty write still fails on a descriptor not open for writing
+		if _, err := f.Write(nil); err != nil {
+			fatalf("invalid progress file descriptor %d: %v", *progressFD, err)
+		}
+		defer f.Close()
+		uiOut = f
+	}
+	reportFound(uiOut, found)
+	alerts := alerter{bell: *bell, notify: *notifyEnd}
+	if len(images) == 0 {
+		// every image was excluded; there is nothing to pull
+		reportExcluded(uiOut, excluded)
+		return exitOK
+	}
+	run := func() model {
+		m := runPulls(images, opts, renderConfig{
+			out:             uiOut,
+			mode:            *progressMode,
+			noCursorControl: *noCursorControl,
+			noRaw:           *noRaw,
+			timestamps:      *timestamps,
+			titleProgress:   *titleProgress,
+			noProgress:      *format == formatJSON,
+		})
+		opts.sinks.end(m.pulls)
+		alerts.alert(uiOut, m)
+		return m
+	}
+	if *watch > 0 {
+		reportExcluded(uiOut, excluded)
+		watchPulls(*watch, uiOut, errOut, run)
+		return exitOK
+	}
+	m := run()
+	pulls := m.pulls
+	if *printRef {
+		printPulledRefs(pulls)
+	}
+	if *output == outputImageID {
+		printImageIDs(pulls)
+	}
+	reportErrors(errOut, pulls)
+	reportExcluded(uiOut, excluded)
+	if *cleanOnCancel {
+		reportLeftovers(uiOut, pulls)
+	}
+	if *platformReport {
+		printPlatformReport(uiOut, pulls)
+	}
+	if *storeReport && opts.simulate == nil && !opts.fromStdin {
+		printImageStore(uiOut, pulls, opts)
+	}
+	if *concurrencyReport {
+		fmt.Fprintln(uiOut)
+		printConcurrencyReport(uiOut, m)
+	}
+	if *histogram {
+		for _, p := range pulls {
+			if p.done && p.cancelReason == notCancelled {
+				fmt.Fprintln(uiOut)
+				printHistogram(uiOut, p, opts)
+			}
+		}
+	}
+	if *groupByRegistry {
+		fmt.Fprintln(uiOut)
+		printRegistrySummary(uiOut, pulls, opts)
 	}
-	p := tea.NewProgram(initialModel(image))
-	if _, err := p.Run(); err != nil {
-		fmt.Println("Error:", err)
-		os.Exit(1)
+	if *reportFormat != "" {
+		fmt.Fprintln(uiOut)
+		printReport(uiOut, pulls, *reportFormat, opts)
 	}
+	return exitCode(pulls)
 }
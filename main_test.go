@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dockerpulltui/pull"
+)
+
+// runFixture replays the stream in testdata/name as image through runPulls,
+// as --from-stdin does, and returns what it rendered and the final model.
+func runFixture(t *testing.T, name, image string, opts options, rc renderConfig) (string, model) {
+	t.Helper()
+	in, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	stdin := os.Stdin
+	os.Stdin = in
+	defer func() { os.Stdin = stdin }()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	opts.fromStdin = true
+	rc.out, rc.noRaw = out, true
+	m := runPulls([]string{image}, opts, rc)
+	b, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), m
+}
+
+// TestRunPullsInstant replays a pull whose every layer already exists, which
+// ends before the first render interval, and checks that its final line and
+// its last JSON event are written by the time runPulls returns.
+func TestRunPullsInstant(t *testing.T) {
+	events, err := os.Create(filepath.Join(t.TempDir(), "events.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	opts := options{sinks: sinks{newJSONSink(events, "")}}
+	out, m := runFixture(t, "already-exists.json", "alpine:3.20", opts, renderConfig{mode: progressPlain})
+	opts.sinks.end(m.pulls)
+
+	if !strings.HasSuffix(out, "alpine:3.20: UP TO DATE\n") {
+		t.Errorf("output = %q, want it to end with the final status", out)
+	}
+	b, err := os.ReadFile(events.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := decodeEvents(t, b)["alpine:3.20"]
+	if ev.Done == nil || !*ev.Done {
+		t.Errorf("last event = %+v, want done", ev)
+	}
+	if got := exitCode(m.pulls); got != exitOK {
+		t.Errorf("exitCode() = %d, want %d", got, exitOK)
+	}
+}
+
+// TestRunPullsPipeModes checks that the modes for pipes and pagers write no
+// escape sequences, such as the clear-line ESC[2K that less without -R
+// shows as garbage; cr mode redraws its line with a bare carriage return.
+func TestRunPullsPipeModes(t *testing.T) {
+	for _, mode := range []string{progressPlain, progressCR} {
+		t.Run(mode, func(t *testing.T) {
+			out, _ := runFixture(t, "shared-layer.json", "app:1", options{}, renderConfig{mode: mode})
+			if strings.Contains(out, "\x1b") {
+				t.Errorf("output contains an escape sequence: %q", out)
+			}
+			if out == "" {
+				t.Error("no output")
+			}
+		})
+	}
+	// auto picks plain when the output is not a terminal
+	out, _ := runFixture(t, "shared-layer.json", "app:1", options{}, renderConfig{mode: progressAuto})
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("auto mode on a file wrote an escape sequence: %q", out)
+	}
+}
+
+// TestRunPullsPanicRestoresTerminal makes a pull panic under the terminal
+// renderer and checks that the terminal is given back, the cursor shown
+// again, before the stack is printed after it.
+func TestRunPullsPanicRestoresTerminal(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	// the stack goes to stderr; share the file to see the order of the two
+	stderr := os.Stderr
+	os.Stderr = out
+	defer func() { os.Stderr = stderr }()
+
+	opts := options{
+		simulate: &simulation{layers: 1, size: 1 << 20, speed: 1 << 30},
+		filters:  []pull.Filter{func(map[string]any) bool { panic("boom") }},
+	}
+	m := runPulls([]string{"node:20"}, opts, renderConfig{out: out, mode: progressTTY, noRaw: true})
+	if m.pulls[0].err == nil {
+		t.Fatal("the panicking pull did not fail")
+	}
+	b, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, stack, ok := strings.Cut(string(b), "panic in pull: boom")
+	if !ok {
+		t.Fatalf("no stack printed: %q", b)
+	}
+	if !strings.HasSuffix(before, string(showCursorSeq)) {
+		t.Errorf("output before the stack = %q, want it to end by showing the cursor", before)
+	}
+	if !strings.Contains(stack, "goroutine") {
+		t.Errorf("stack = %q, want a goroutine trace", stack)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// manifestImages are the image refs found in one Compose file or Kubernetes
+// manifest.
+type manifestImages struct {
+	path   string
+	images []string
+}
+
+// readManifestImages returns the value of every image: key in the YAML file
+// at path, in order and without duplicates. It reads no schema, so it serves
+// Compose files and Kubernetes manifests alike, including multi-document
+// ones, and ignores every other key. Compose's ${VAR} interpolation is not
+// expanded; such refs fail validation.
+func readManifestImages(path string) (manifestImages, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return manifestImages{}, err
+	}
+	defer f.Close()
+	found := manifestImages{path: path}
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		img, ok := imageValue(sc.Text())
+		if !ok || seen[img] {
+			continue
+		}
+		seen[img] = true
+		found.images = append(found.images, img)
+	}
+	if err := sc.Err(); err != nil {
+		return manifestImages{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return found, nil
+}
+
+// imageValue returns the value of line if it is an image: key, either a
+// mapping entry or the first entry of a sequence item ("- image: nginx").
+func imageValue(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if rest, ok := strings.CutPrefix(line, "- "); ok {
+		line = strings.TrimSpace(rest)
+	}
+	for _, key := range []string{"image:", `"image":`, "'image':"} {
+		if rest, ok := strings.CutPrefix(line, key); ok {
+			return scalarValue(rest)
+		}
+	}
+	return "", false
+}
+
+// scalarValue unquotes a plain or quoted YAML scalar and drops a trailing
+// comment. Anything else, such as an empty value or a block, is not an image.
+func scalarValue(v string) (string, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", false
+	}
+	if q := v[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(v[1:], q)
+		if end < 0 {
+			return "", false
+		}
+		v = v[1 : end+1]
+	} else {
+		if i := strings.Index(v, " #"); i >= 0 {
+			v = v[:i]
+		}
+		v = strings.TrimSpace(v)
+		if strings.ContainsAny(v[:1], "|>{[&*") {
+			return "", false
+		}
+	}
+	return v, v != ""
+}
+
+// reportFound lists the images read from each manifest before they are
+// pulled.
+func reportFound(w io.Writer, found []manifestImages) {
+	for _, m := range found {
+		if len(m.images) == 0 {
+			fmt.Fprintf(w, "Found no images in %s\n", m.path)
+			continue
+		}
+		fmt.Fprintf(w, "Found in %s: %s\n", m.path, strings.Join(m.images, ", "))
+	}
+}
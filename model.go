@@ -0,0 +1,1029 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"dockerpulltui/pull"
+	"dockerpulltui/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/docker/docker/client"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/text/message"
+)
+
+// now is the clock of every time-dependent feature: throttling, rates and
+// ETAs, timers and cooldowns. Tests may replace it to run them without
+// sleeping.
+var now = time.Now
+
+type layerState struct {
+	current int64
+	total   int64
+	status  string
+	done    bool
+	// extractCurrent and extractTotal track the Extracting phase separately
+	extractCurrent int64
+	extractTotal   int64
+	// baseline counts the bytes of the layer this pull did not transfer: a
+	// download resumed after a retry starts above 0, and a layer that
+	// completes without any bytes seen was fetched by someone else
+	baseline int64
+	// resuming is set by a retry until the next Downloading event
+	resuming bool
+	// progress is the daemon's rendering of the last message, if any; only
+	// --plain-layers shows it
+	progress string
+	// phase classifies status
+	phase pull.Phase
+	// rate is the speed of the download or extraction in flight; only
+	// sampled with --eta
+	rate layerRate
+}
+
+// complete reports whether the layer is fully pulled.
+func (ls layerState) complete() bool {
+	return ls.phase.Present()
+}
+
+// extracted returns the fraction of the layer that has been extracted.
+func (ls layerState) extracted() float64 {
+	switch {
+	case ls.complete():
+		return 1
+	case ls.extractTotal > 0:
+		return float64(ls.extractCurrent) / float64(ls.extractTotal)
+	}
+	return 0
+}
+
+// apply folds a progress event of this layer, whose status is in phase ph,
+// into its state.
+func (ls *layerState) apply(ev pull.Event, ph pull.Phase) {
+	// current and total count downloaded bytes; extraction is tracked apart.
+	// A message without counts must not reset them.
+	prev := ls.current
+	if ph != pull.PhaseExtracting && ev.HasCounts {
+		if ev.Total > 0 {
+			ls.total = ev.Total
+		}
+		if ev.Current > 0 {
+			ls.current = ev.Current
+		}
+	}
+	switch {
+	case ph == pull.PhaseRetrying:
+		ls.resuming = true
+	case ph == pull.PhaseDownloading && ls.resuming:
+		// the first chunk of a plain download is transferred like the rest;
+		// only a retry resumes from bytes the daemon kept
+		if prev == 0 {
+			ls.baseline = ls.current
+		}
+		ls.resuming = false
+	}
+	if ev.Status != "" {
+		ls.status = ev.Status
+		ls.phase = ph
+		ls.progress = ev.Progress
+	}
+	if ph.Done() {
+		ls.done = true
+		if ls.total > 0 && ls.current < ls.total {
+			if ls.current == ls.baseline {
+				// no byte of it was seen downloading, e.g. another process
+				// pulling the same image fetched it: present, not transferred
+				ls.baseline = ls.total
+			}
+			ls.current = ls.total
+		}
+	}
+	if ph == pull.PhaseExtracting && ev.Total > 0 {
+		ls.extractCurrent, ls.extractTotal = ev.Current, ev.Total
+	}
+}
+
+// sampleRate samples the byte count of the phase the layer is in, if it
+// moves bytes.
+func (ls *layerState) sampleRate(at time.Time, alpha float64) {
+	switch ls.phase {
+	case pull.PhaseDownloading:
+		ls.rate.sample(at, ls.current, alpha)
+	case pull.PhaseExtracting:
+		ls.rate.sample(at, ls.extractCurrent, alpha)
+	default:
+		ls.rate = layerRate{}
+	}
+}
+
+// imagePull tracks the progress of a single image within a (possibly batched) run.
+type imagePull struct {
+	image     string
+	layers    *layerSet
+	pl        *ui.ProgressLine
+	msgCh     chan tea.Msg
+	started   bool
+	startedAt time.Time
+	endedAt   time.Time
+	// cancelReason is set when the pull was cancelled while running
+	cancelReason cancelReason
+	done         bool
+	// present is set when the pull was skipped because the image exists locally
+	present bool
+	err     error
+	imageResult
+	// digest is the manifest digest reported at the end of the stream
+	digest string
+	// message is the last status of the stream not about a layer, such as
+	// "Digest: …" or "Status: Downloaded newer image for …"
+	message string
+	// hideBar avoids showing the bar for up-to-date pulls
+	hideBar     bool
+	sawDownload bool
+	// sawProgress latches on the first event that moved bytes
+	sawProgress bool
+	meter       *rateMeter
+	// transferred counts the bytes this pull downloaded, excluding resumed ones
+	transferred int64
+	// reused counts the bytes of known size the daemon already had, from
+	// present layers and resumed downloads; existing counts present layers,
+	// sized or not
+	reused   int64
+	existing int
+	// byLayers is set while the stream reports no byte totals and the bar
+	// shows the fraction of finished layers instead
+	byLayers bool
+	// cooldown is the pull's current --respect-rate-limit wait, if any
+	cooldown pullCooldown
+	// completing is set while a successful pull shows its full bar for a
+	// last frame; it can no longer be cancelled
+	completing bool
+	// draining is set while a pull declared complete at download still
+	// extracts; see finalizeDownload
+	draining bool
+	// cols is the terminal width left for p's line, once known
+	cols int
+	// concurrency tracks how many of p's layers download at once
+	concurrency concurrency
+}
+
+// downloaded reports whether every layer of p has finished downloading.
+func (p *imagePull) downloaded() bool {
+	n := p.layers.len()
+	return n > 0 && p.layers.totals().downloaded == n
+}
+
+// coolingDown reports whether p waits out a rate limit.
+func (p *imagePull) coolingDown() bool {
+	return !p.cooldown.until.IsZero()
+}
+
+func newImagePull(image string, smoothing float64) *imagePull {
+	label := fmt.Sprintf("Pulling %s", image)
+	return &imagePull{
+		image:  image,
+		layers: newLayerSet(),
+		pl:     ui.NewProgressLine(label),
+		msgCh:  make(chan tea.Msg, 256),
+		meter:  newRateMeter(smoothing),
+	}
+}
+
+// layerCounts returns how many of the layers seen so far are fully pulled, and
+// how many there are.
+func (p *imagePull) layerCounts() (done, total int) {
+	return p.layers.totals().complete, p.layers.len()
+}
+
+func (p *imagePull) finished() bool {
+	return p.done || p.err != nil
+}
+
+// options configures a run; the zero value pulls every image at once and
+// renders only the terminal UI.
+type options struct {
+	// parallel bounds the number of concurrent pulls; 0 means unbounded
+	parallel int
+	// sinks receive the run's progress besides the bars, e.g. BuildKit-style
+	// rawjson
+	sinks sinks
+	// fromStdin replays a captured pull stream from stdin instead of pulling
+	fromStdin bool
+	// simulate, if set, renders a synthetic pull instead of pulling
+	simulate *simulation
+	// replaySpeed paces replayed messages; 0 replays them as fast as possible
+	replaySpeed float64
+	// pullPolicy is one of pullAlways, pullMissing or pullNever
+	pullPolicy string
+	// filters can drop or rewrite raw stream messages before they are applied
+	filters []pull.Filter
+	// elapsed appends a running timer, e.g. 3m12s, to in-progress lines
+	elapsed bool
+	// clientOpts configure the daemon client, e.g. from a docker context
+	clientOpts []client.Opt
+	// eta appends the smoothed transfer rate and ETA to in-progress lines
+	eta bool
+	// smoothing is the rate EWMA factor in (0,1]
+	smoothing float64
+	// quietOnUpToDate prints nothing for pulls that transfer no bytes
+	quietOnUpToDate bool
+	// platformReport looks up the daemon's platform to compare pulled
+	// images against
+	platformReport bool
+	// sparkline shows recent throughput next to the bar
+	sparkline bool
+	// mergeLayers counts layers shared between pulls once in totals across
+	// pulls
+	mergeLayers bool
+	// finalizeOn is when a pull counts as complete: finalizeExtract or
+	// finalizeDownload
+	finalizeOn string
+	// checksumProgress shows the verify/extract phase once downloads finish
+	checksumProgress bool
+	// labelWidth fixes the label column; 0 pads labels to the longest one
+	labelWidth int
+	// since holds back the bar until bytes move or this warmup has passed
+	since time.Duration
+	// status holds the final status words
+	status statusText
+	// extractWeight is the share of the overall bar given to extraction; the
+	// rest goes to downloading
+	extractWeight float64
+	// prefetchManifest fetches each image's manifest before pulling it, so
+	// the bar knows the full size from the start
+	prefetchManifest bool
+	// glyphs are the characters drawn beyond plain text, ASCII-only for
+	// --ascii-only
+	glyphs glyphSet
+	// maxRuntime, if positive, bounds the run; see startWatchdog
+	maxRuntime time.Duration
+	// cacheBreakdown adds the bytes downloaded and reused to final lines
+	cacheBreakdown bool
+	// phases classifies the statuses of the stream; initialModel defaults it
+	// to pull.DockerPhases
+	phases pull.PhaseClassifier
+	// heartbeat animates a marker after a stalled bar
+	heartbeat bool
+	// plainLayers prints docker's own per-layer lines in plain and cr modes
+	plainLayers bool
+	// compactWhenNarrow drops parts of a running pull's line, and then
+	// shrinks its bar, rather than let the line wrap
+	compactWhenNarrow bool
+	// compactBytes shortens byte counts for narrow terminals
+	compactBytes bool
+	// siBytes counts bytes in decimal units, as docker images does
+	siBytes bool
+	// compactStatus collapses transient layer statuses wherever layers are
+	// listed
+	compactStatus bool
+	// tag, if set, is applied to the image after a successful pull
+	tag string
+	// failFast cancels the remaining pulls once one fails
+	failFast bool
+	// printer, if set, formats numbers for --locale
+	printer *message.Printer
+	// respectRateLimit waits out rate limits and retries the pull
+	respectRateLimit bool
+	// promptAuth prompts for credentials when the daemon refuses a pull as
+	// unauthorized; only set when the program owns an interactive terminal
+	promptAuth bool
+	// cleanOnCancel reports what cancelled pulls leave behind
+	cleanOnCancel bool
+	// minBarWidth and maxBarWidth clamp the bar when it is fitted to the
+	// terminal width
+	minBarWidth, maxBarWidth int
+}
+
+// bytes formats n for display according to opts.
+func (o options) bytes(n int64) string {
+	base := 1024
+	if o.siBytes {
+		base = 1000
+	}
+	switch {
+	case o.printer != nil && o.compactBytes:
+		return formatBytesWith(o.sprintf, n, -1, base)
+	case o.printer != nil:
+		return formatBytesWith(o.sprintf, n, 2, base)
+	case o.compactBytes:
+		return formatBytesWith(fmt.Sprintf, n, -1, base)
+	}
+	return humanBytesBase(n, base)
+}
+
+// sprintf is fmt.Sprintf, or the --locale printer's when one is set.
+func (o options) sprintf(format string, a ...any) string {
+	if o.printer != nil {
+		return o.printer.Sprintf(format, a...)
+	}
+	return fmt.Sprintf(format, a...)
+}
+
+// warmupMsg re-renders once a pull's --since warmup has passed.
+type warmupMsg struct{}
+
+// cooldownTickMsg refreshes rate limit countdowns once a second.
+type cooldownTickMsg struct{}
+
+func tickCooldown() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return cooldownTickMsg{} })
+}
+
+// Completion points for --finalize-on.
+const (
+	// finalizeExtract completes a pull when the daemon reports it pulled,
+	// with every layer extracted.
+	finalizeExtract = "extract"
+	// finalizeDownload completes a pull once every layer has downloaded:
+	// its final status shows and its --parallel slot goes to the next image
+	// while extraction goes on. The daemon stops a pull whose client
+	// disconnects, so the tool still exits only after extraction, and a
+	// failed extraction turns the pull back to failed.
+	finalizeDownload = "download"
+)
+
+// stallAfter is how long a bar must not move before --heartbeat marks it as
+// stalled.
+const stallAfter = 2 * time.Second
+
+// finalFrameDelay is how long a completed pull's bar stays at 100% before
+// its final status replaces it; long enough for at least one frame.
+const finalFrameDelay = 100 * time.Millisecond
+
+// pullCompleted ends pull idx once its bar has been shown at 100%.
+type pullCompleted struct{ idx int }
+
+// elapsedTickMsg refreshes the elapsed timers once a second.
+type elapsedTickMsg struct{}
+
+func tickElapsed() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return elapsedTickMsg{} })
+}
+
+type model struct {
+	pulls  []*imagePull
+	opts   options
+	ctx    context.Context
+	cancel context.CancelFunc
+	// cancelReason is set once the run has been cancelled
+	cancelReason cancelReason
+	// width and height are the terminal size; offset is the first visible
+	// list row
+	width  int
+	height int
+	offset int
+	// detailed shows every running pull's layers below its line
+	detailed bool
+	// quitting is set once every pull has ended, for the final frame
+	quitting bool
+	// crashed holds the first panic of a pull goroutine, whose stack is
+	// printed after Run
+	crashed *pullPanic
+	// concurrency tracks how many layers download at once across pulls
+	concurrency concurrency
+}
+
+func initialModel(images []string, opts options) model {
+	opts.status = opts.status.withDefaults()
+	if opts.phases == nil {
+		opts.phases = pull.DockerPhases
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	pulls := make([]*imagePull, 0, len(images))
+	width := opts.labelWidth
+	for _, img := range images {
+		p := newImagePull(img, opts.smoothing)
+		pulls = append(pulls, p)
+		if opts.labelWidth == 0 {
+			width = max(width, runewidth.StringWidth(p.pl.Label))
+		}
+	}
+	for _, p := range pulls {
+		p.pl.LabelWidth = width
+		p.pl.Ellipsis = opts.glyphs.ellipsis
+		if opts.heartbeat {
+			// tick while stalled, and stop once the line is done
+			p.pl.Heartbeat, p.pl.IdleAfter = stallAfter, stallAfter
+		}
+		p.pl.Bar.Full, p.pl.Bar.Empty = opts.glyphs.barFull, opts.glyphs.barEmpty
+		p.pl.Bar.Width = min(max(p.pl.Bar.Width, opts.minBarWidth), opts.maxBarWidth)
+	}
+	return model{
+		pulls:  pulls,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// widestDuration is the longest a humanDuration usually gets, e.g. 59m59s.
+const widestDuration = time.Hour - time.Second
+
+// suffixWidth returns the widest the enabled columns after a bar get, each
+// formatted as View formats it.
+func (o options) suffixWidth() int {
+	w := 0
+	if o.eta {
+		// just under a unit prints the most digits, e.g. 1023.99MB
+		widest := int64(1<<30 - 1)
+		if o.siBytes {
+			widest = 1e9 - 1
+		}
+		w += runewidth.StringWidth(fmt.Sprintf("  %s/s ETA %s", o.bytes(widest), humanDuration(widestDuration)))
+	}
+	if o.elapsed {
+		w += len("  ") + len(humanDuration(widestDuration))
+	}
+	if o.checksumProgress {
+		w += len("  extracting 100%")
+	}
+	if o.sparkline {
+		w += len("  ") + sparklineSamples
+	}
+	return w
+}
+
+// fitBars sizes every bar to what is left of the terminal width once the
+// label and the enabled suffixes are drawn, within the bar clamps.
+func (m model) fitBars() {
+	if m.width == 0 {
+		return
+	}
+	suffix := m.opts.suffixWidth()
+	for _, p := range m.pulls {
+		label := p.pl.LabelWidth
+		if label == 0 {
+			label = runewidth.StringWidth(p.pl.Label)
+		}
+		avail := m.width - len(m.batchPrefix(0)) - label - len("... ") - suffix
+		if p.byLayers {
+			avail -= len("  layers 999/999")
+		}
+		p.pl.Bar.Width = min(max(avail, m.opts.minBarWidth), m.opts.maxBarWidth)
+		p.cols = m.width - len(m.batchPrefix(0))
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	cmds := m.startQueued()
+	if m.opts.elapsed {
+		cmds = append(cmds, tickElapsed())
+	}
+	if m.opts.eta || m.opts.sparkline {
+		cmds = append(cmds, tickRates())
+	}
+	return tea.Batch(cmds...)
+}
+
+// coolingDown reports whether any pull waits out a rate limit.
+func (m model) coolingDown() bool {
+	for _, p := range m.pulls {
+		if p.coolingDown() {
+			return true
+		}
+	}
+	return false
+}
+
+// running returns the number of pulls that have started but not yet ended.
+func (m model) running() int {
+	n := 0
+	for _, p := range m.pulls {
+		if p.started && !p.finished() {
+			n++
+		}
+	}
+	return n
+}
+
+// startQueued starts waiting pulls until the parallel limit is reached.
+func (m model) startQueued() []tea.Cmd {
+	var cmds []tea.Cmd
+	active := m.running()
+	for i, p := range m.pulls {
+		if m.opts.parallel > 0 && active >= m.opts.parallel {
+			break
+		}
+		if p.started {
+			continue
+		}
+		p.started = true
+		p.startedAt = now()
+		active++
+		m.opts.sinks.pullChanged(p)
+		go pullImage(m.ctx, i, p.image, m.opts, p.msgCh)
+		cmds = append(cmds, waitForMsg(i, p.msgCh), p.pl.InitCmd())
+		if m.opts.since > 0 {
+			cmds = append(cmds, tea.Tick(m.opts.since, func(time.Time) tea.Msg { return warmupMsg{} }))
+		}
+	}
+	return cmds
+}
+
+// finish is called after p ends; it starts the next queued pull or quits
+// once every started pull has ended.
+func (m model) finish(p *imagePull) (tea.Model, tea.Cmd) {
+	if !p.endedAt.IsZero() {
+		// already ended, as a pull declared complete at download is before
+		// its stream drains: its slot was given up then, and its end only
+		// reports what the stream's tail changed, such as a wrong digest
+		m.opts.sinks.pullChanged(p)
+		return m.quitIfDone()
+	}
+	p.endedAt = now()
+	m.opts.sinks.pullChanged(p)
+	if m.cancelReason == notCancelled {
+		if cmds := m.startQueued(); len(cmds) > 0 {
+			return m, tea.Batch(cmds...)
+		}
+	}
+	return m.quitIfDone()
+}
+
+// quitIfDone quits once no pull runs or drains.
+func (m model) quitIfDone() (tea.Model, tea.Cmd) {
+	if m.running() > 0 || m.draining() {
+		return m, nil
+	}
+	m.quitting = true
+	return m, tea.Quit
+}
+
+// complete marks pull idx successful. Every path to success shows the bar at
+// 100% for a frame before the final status, when there is a bar.
+func (m model) complete(idx int) (tea.Model, tea.Cmd) {
+	p := m.pulls[idx]
+	if p.cancelReason != notCancelled {
+		// its stream ended because it was cancelled; the bar keeps the
+		// progress it had
+		p.done = true
+		return m.finish(p)
+	}
+	_, _ = p.pl.Update(ui.DoneMsg{})
+	if p.barVisible(m.opts) {
+		p.completing = true
+		return m, tea.Tick(finalFrameDelay, func(time.Time) tea.Msg { return pullCompleted{idx} })
+	}
+	p.done = true
+	return m.finish(p)
+}
+
+// draining reports whether a pull declared complete under
+// --finalize-on=download still awaits the end of its stream.
+func (m model) draining() bool {
+	for _, p := range m.pulls {
+		if p.draining {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelAll cancels the run for reason, marking the pulls still running.
+// Queued pulls will not start and end at once, cancelled. Only the first
+// cancellation counts.
+func (m *model) cancelAll(reason cancelReason) {
+	if m.cancelReason != notCancelled {
+		return
+	}
+	m.cancelReason = reason
+	for _, p := range m.pulls {
+		switch {
+		case !p.started:
+			p.cancelReason, p.done = reason, true
+			p.endedAt = now()
+			m.opts.sinks.pullChanged(p)
+		case !p.finished() && !p.completing:
+			p.cancelReason = reason
+		}
+	}
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.clampOffset()
+		m.fitBars()
+		return m, nil
+	case tea.KeyMsg:
+		if msg.String() == detailKey {
+			m.detailed = !m.detailed
+			m.clampOffset()
+			return m, nil
+		}
+		if m.isBatch() && m.scroll(msg) {
+			return m, nil
+		}
+		// Let component swallow keys; it emits ui.CancelMsg on Esc/Ctrl-C,
+		// which cancelFilter turns into a cancelMsg
+		if cmd, handled := m.pulls[0].pl.Update(msg); handled {
+			return m, cmd
+		}
+		return m, nil
+	case cancelMsg:
+		m.cancelAll(msg.reason)
+		return m, nil
+	case elapsedTickMsg:
+		return m, tickElapsed()
+	case rateTickMsg:
+		for _, p := range m.pulls {
+			if p.started && !p.finished() {
+				p.sampleRates(m.opts)
+			}
+		}
+		return m, tickRates()
+	case pullCooldown:
+		p := m.pulls[msg.idx]
+		cooling := m.coolingDown()
+		p.cooldown = msg
+		var cmd tea.Cmd
+		if p.coolingDown() && !cooling {
+			cmd = tickCooldown()
+		}
+		return m, tea.Batch(cmd, waitForMsg(msg.idx, p.msgCh))
+	case authPrompt:
+		p := m.pulls[msg.idx]
+		return m, tea.Batch(tea.Exec(&credentialPrompt{authPrompt: msg}, nil), waitForMsg(msg.idx, p.msgCh))
+	case cooldownTickMsg:
+		if m.coolingDown() {
+			return m, tickCooldown()
+		}
+		return m, nil
+	case progressEvent:
+		p := m.pulls[msg.idx]
+		byLayers := p.byLayers
+		cmd := p.apply(msg, m.opts)
+		if p.byLayers != byLayers {
+			m.fitBars()
+		}
+		downloading := 0
+		for _, q := range m.pulls {
+			if q.started && !q.finished() {
+				downloading += q.layers.totals().downloading
+			}
+		}
+		m.concurrency.sample(downloading)
+		switch {
+		case msg.ID != "":
+			m.opts.sinks.layerChanged(p, msg.ID)
+		case msg.Status != "":
+			// image-level statuses such as the digest
+			m.opts.sinks.pullChanged(p)
+		}
+		wait := waitForMsg(msg.idx, p.msgCh)
+		if m.opts.finalizeOn == finalizeDownload && p.downloaded() && !p.finished() && !p.completing && p.cancelReason == notCancelled {
+			// declared complete now, but the stream is still followed to
+			// its end, as the daemon abandons a pull nobody listens to
+			p.draining = true
+			next, done := m.complete(msg.idx)
+			return next, tea.Batch(cmd, done, wait)
+		}
+		return m, tea.Batch(cmd, wait)
+	case expectedLayers:
+		p := m.pulls[msg.idx]
+		for _, l := range msg.layers {
+			// the stream names layers by a prefix of their digest
+			p.layers.expect(shortID(l.Digest.String()), l.Size)
+		}
+		return m, waitForMsg(msg.idx, p.msgCh)
+	case pullDone:
+		p := m.pulls[msg.idx]
+		p.imageResult = msg.imageResult
+		draining := p.draining
+		p.draining = false
+		// the stream echoes the manifest digest; for a pinned ref it must match
+		if want := requestedDigest(p.image); want != "" && p.digest != "" && p.digest != want {
+			p.err = fmt.Errorf("pulled digest %s does not match requested %s", p.digest, want)
+			return m.finish(p)
+		}
+		switch {
+		case draining && p.completing:
+			// pullCompleted will end it
+			return m, nil
+		case draining:
+			return m.finish(p)
+		}
+		return m.complete(msg.idx)
+	case pullCompleted:
+		p := m.pulls[msg.idx]
+		p.completing = false
+		if p.err != nil {
+			// failed during its last frame, and already ended
+			return m, nil
+		}
+		p.done = true
+		return m.finish(p)
+	case pullPanic:
+		p := m.pulls[msg.idx]
+		p.draining = false
+		p.err = fmt.Errorf("panic: %v", msg.value)
+		if m.crashed == nil {
+			m.crashed = &msg
+		}
+		return m.finish(p)
+	case pullPresent:
+		p := m.pulls[msg.idx]
+		p.done = true
+		p.present = true
+		p.imageResult = msg.imageResult
+		return m.finish(p)
+	case pullErr:
+		p := m.pulls[msg.idx]
+		p.draining = false
+		// Treat context canceled as clean exit
+		if isCanceled(msg.err) {
+			p.done = true
+		} else {
+			p.err = msg.err
+			if m.opts.failFast {
+				m.cancelAll(cancelFailFast)
+			}
+		}
+		return m.finish(p)
+	default:
+		if m.opts.heartbeat {
+			// the lines' animation ticker redraws the heartbeat
+			for _, p := range m.pulls {
+				if cmd, handled := p.pl.Update(msg); handled {
+					return m, cmd
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// apply folds a progress event into the pull's layer state and returns the
+// command produced by updating the progress line.
+func (p *imagePull) apply(msg progressEvent, opts options) tea.Cmd {
+	ph := opts.phases.Classify(msg.Status)
+	switch ph {
+	case pull.PhaseResolving:
+		// ignore top-level header
+		return nil
+	case pull.PhaseUpToDate:
+		p.hideBar = true
+	case pull.PhaseDownloading, pull.PhaseDownloaded, pull.PhaseExtracting, pull.PhaseUpdated:
+		p.sawDownload = true
+	}
+	if d, ok := strings.CutPrefix(msg.Status, "Digest: "); ok {
+		p.digest = d
+	}
+	if msg.ID == "" && msg.Status != "" {
+		p.message = msg.Status
+	}
+	if msg.Current > 0 {
+		p.sawProgress = true
+	}
+	if msg.ID != "" {
+		p.layers.update(msg.ID, func(ls *layerState) {
+			ls.apply(msg.Event, ph)
+			if opts.eta {
+				ls.sampleRate(now(), opts.smoothing)
+			}
+		})
+	}
+	sums := p.layers.totals()
+	n := p.layers.len()
+	p.concurrency.sample(sums.downloading)
+	pct, allDone, known := computeOverall(sums, n, opts.extractWeight, p.pl.Percent)
+	// the rate only counts bytes moved by this pull, not resumed ones
+	p.transferred = sums.transferred
+	p.reused, p.existing = sums.current-sums.transferred, sums.existing
+	p.sampleMeter(sums)
+	// If all done and we never downloaded anything, hide the bar entirely
+	if allDone && !p.sawDownload {
+		p.hideBar = true
+	}
+	p.byLayers = sums.total == 0 && n > 0
+	if !known {
+		return nil
+	}
+	if c, handled := p.pl.Update(ui.SetPercentMsg{Pct: pct}); handled {
+		return c
+	}
+	return nil
+}
+
+// computeOverall returns the overall fraction of a pull from the sums over
+// its n layers, weighting each layer's download and extract phases by its
+// size by extractWeight. Without any byte totals it falls back to the
+// fraction of finished layers, and known is false when there is not even a
+// layer. The fraction stays below 1 until allDone and never drops below prev,
+// the fraction shown before.
+func computeOverall(sums layerTotals, n int, extractWeight, prev float64) (pct float64, allDone, known bool) {
+	allDone = sums.complete == n
+	switch {
+	case sums.total > 0:
+		weighted := (1-extractWeight)*float64(sums.current) + extractWeight*float64(sums.extracted)
+		pct = weighted / float64(sums.total)
+	case n > 0:
+		pct = float64(sums.complete) / float64(n)
+	default:
+		return 0, allDone, false
+	}
+	if !allDone && pct >= 0.999 {
+		pct = 0.99
+	}
+	return max(pct, prev), allDone, true
+}
+
+// sampleMeter samples p's transfer rate from the sums over its layers.
+func (p *imagePull) sampleMeter(sums layerTotals) {
+	// the rate counts every byte moved, the ETA only those of known sizes
+	p.meter.sample(now(), sums.moved, sums.moved+sums.total-sums.current)
+}
+
+// sampleRates samples p's rates without an event, so that they fall while
+// nothing arrives.
+func (p *imagePull) sampleRates(opts options) {
+	p.sampleMeter(p.layers.totals())
+	if opts.eta {
+		at := now()
+		p.layers.updateEach(func(ls *layerState) { ls.sampleRate(at, opts.smoothing) })
+	}
+}
+
+// quiet reports whether --quiet-on-uptodate hides p: nothing to show unless
+// bytes actually move, though failures and cancellations are always shown.
+func (p *imagePull) quiet(opts options) bool {
+	return opts.quietOnUpToDate && !p.sawProgress && p.cancelReason == notCancelled && p.err == nil
+}
+
+// outcome returns the final status of an ended pull, or "" while it runs.
+func (p *imagePull) outcome(opts options) string {
+	st := opts.status
+	switch {
+	case p.cancelReason != notCancelled && opts.cleanOnCancel:
+		done, total := p.layerCounts()
+		return fmt.Sprintf("%s (%s), %d/%d layers complete", st.Cancelled, p.cancelReason, done, total)
+	case p.cancelReason != notCancelled:
+		return fmt.Sprintf("%s (%s)", st.Cancelled, p.cancelReason)
+	case p.err != nil:
+		return st.Failed
+	case p.present:
+		return st.Present + p.summary(opts)
+	case p.done && (p.hideBar || !p.sawDownload):
+		return st.UpToDate + p.summary(opts)
+	case p.done:
+		return st.Done + p.summary(opts)
+	}
+	return ""
+}
+
+func (p *imagePull) View(opts options) string {
+	if p.quiet(opts) {
+		return ""
+	}
+	if s := p.outcome(opts); s != "" {
+		return fmt.Sprintf("Pulling %s...%s\n", p.image, s)
+	}
+	timer := ""
+	if opts.elapsed {
+		timer = "  " + humanDuration(now().Sub(p.startedAt))
+	}
+	if p.coolingDown() {
+		left := p.cooldown.until.Sub(now()).Round(time.Second)
+		return fmt.Sprintf("Pulling %s...rate limited, retrying in %s%s\n", p.image, humanDuration(left), timer)
+	}
+	// If we haven't seen any downloading/extracting yet, or we've determined the image is up to date, hide the bar
+	if !p.barVisible(opts) {
+		return fmt.Sprintf("Pulling %s...%s\n", p.image, timer)
+	}
+	var elements []lineElement
+	if opts.checksumProgress {
+		if ph := p.phase(); ph != "" {
+			elements = append(elements, lineElement{"  " + ph, dropPhase})
+		}
+	}
+	if p.byLayers {
+		done, total := p.layerCounts()
+		elements = append(elements, lineElement{fmt.Sprintf("  layers %d/%d", done, total), dropLayers})
+	}
+	if opts.sparkline {
+		if recent := p.meter.recent(); len(recent) > 0 {
+			elements = append(elements, lineElement{"  " + sparkline(recent, opts.glyphs.spark), dropSparkline})
+		}
+	}
+	if opts.eta && p.meter.rate > 0 {
+		rate := fmt.Sprintf("  %s/s", opts.bytes(int64(p.meter.rate)))
+		if p.meter.eta > 0 {
+			rate += " ETA " + humanDuration(p.meter.eta)
+		}
+		elements = append(elements, lineElement{rate, dropRate})
+	}
+	if timer != "" {
+		elements = append(elements, lineElement{timer, dropTimer})
+	}
+	if opts.compactWhenNarrow && p.cols > 0 {
+		return p.layoutLine(p.cols, elements) + "\n"
+	}
+	return p.pl.View() + joinElements(elements) + "\n"
+}
+
+// barVisible reports whether p's line shows a bar while it runs.
+func (p *imagePull) barVisible(opts options) bool {
+	return !p.hideBar && p.sawDownload && !p.warmingUp(opts)
+}
+
+// warmingUp reports whether the --since warmup still holds back the bar: no
+// bytes have moved yet and the warmup has not passed.
+func (p *imagePull) warmingUp(opts options) bool {
+	return opts.since > 0 && !p.sawProgress && now().Sub(p.startedAt) < opts.since
+}
+
+// phase describes the CPU-bound tail of the pull, e.g. "extracting 60%", once
+// every layer has downloaded. It returns "" while downloads are in flight.
+func (p *imagePull) phase() string {
+	var cur, total int64
+	verifying, extracting := false, false
+	for _, ls := range p.layers.snapshot() {
+		switch ls.phase {
+		case pull.PhaseVerifying:
+			verifying = true
+		case pull.PhaseExtracting:
+			extracting = true
+		case pull.PhaseComplete:
+			// extracted; count it whole even if no Extracting progress was seen
+			if ls.extractTotal == 0 {
+				ls.extractTotal = ls.total
+			}
+			ls.extractCurrent = ls.extractTotal
+		case pull.PhaseDownloaded:
+			// still to extract; count its size so the percentage does not
+			// fall back once it starts
+			if ls.extractTotal == 0 {
+				ls.extractTotal = ls.total
+			}
+		case pull.PhaseExists:
+		default:
+			return ""
+		}
+		cur += ls.extractCurrent
+		total += ls.extractTotal
+	}
+	switch {
+	case extracting && total > 0:
+		return fmt.Sprintf("extracting %d%%", 100*cur/total)
+	case extracting:
+		return "extracting"
+	case verifying:
+		return "verifying"
+	}
+	return ""
+}
+
+// cacheBreakdown tells how much of p was downloaded and how much the daemon
+// already had, e.g. "downloaded 40.00MB, reused 80.00MB". The stream gives no
+// size for a layer that already exists unless --prefetch-manifest knew it, so
+// such layers are counted instead.
+func (p *imagePull) cacheBreakdown(opts options) string {
+	s := "downloaded " + opts.bytes(p.transferred)
+	switch {
+	case p.reused > 0:
+		s += ", reused " + opts.bytes(p.reused)
+	case p.existing > 0:
+		s += opts.sprintf(", reused %d layers", p.existing)
+	}
+	return s
+}
+
+// summary describes the resulting image and its --tag for the final line,
+// or returns "" when there is nothing to add.
+func (p *imagePull) summary(opts options) string {
+	var s string
+	if p.imageID != "" {
+		s = fmt.Sprintf(" %s (%s)", shortID(p.imageID), opts.bytes(p.size))
+	}
+	if tag := resolvedTag(p.image, p.repoTags); tag != "" {
+		s += ", known as " + tag
+	}
+	if opts.cacheBreakdown && p.sawDownload {
+		s += ", " + p.cacheBreakdown(opts)
+	}
+	switch {
+	case p.tagged:
+		s += ", tagged " + opts.tag
+	case p.tagErr != nil:
+		s += ", tag failed"
+	}
+	return s
+}
+
+func (m model) View() string {
+	if m.isBatch() {
+		return m.listView()
+	}
+	s := m.pulls[0].View(m.opts)
+	if m.detailed {
+		s += strings.Join(m.pulls[0].layerLines(m.opts), "")
+	}
+	return s
+}
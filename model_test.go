@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCacheBreakdown(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    string
+	}{
+		// fresh pulls: every layer's first chunk was downloaded too
+		{"pull-by-digest.json", "downloaded 4.00MB"},
+		{"empty-progress-detail.json", "downloaded 6.00MB"},
+		// a layer completing without any bytes seen was fetched elsewhere
+		{"shared-layer.json", "downloaded 5.00MB, reused 30.00MB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			p := replayFixture(t, tt.fixture, options{})
+			if got := p.cacheBreakdown(options{}); got != tt.want {
+				t.Errorf("cacheBreakdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayerStateResume(t *testing.T) {
+	const mb = 1 << 20
+	tests := []struct {
+		name   string
+		events []pull.Event
+		// reused is the bytes not transferred by this pull
+		reused int64
+	}{
+		{
+			name: "fresh download",
+			events: []pull.Event{
+				{Status: "Downloading", Current: mb, Total: 4 * mb, HasCounts: true},
+				{Status: "Downloading", Current: 4 * mb, Total: 4 * mb, HasCounts: true},
+				{Status: "Download complete"},
+			},
+		},
+		{
+			name: "resumed after a retry",
+			events: []pull.Event{
+				{Status: "Retrying in 5 seconds"},
+				{Status: "Downloading", Current: 3 * mb, Total: 4 * mb, HasCounts: true},
+				{Status: "Downloading", Current: 4 * mb, Total: 4 * mb, HasCounts: true},
+				{Status: "Download complete"},
+			},
+			reused: 3 * mb,
+		},
+		{
+			name: "retried after own progress",
+			events: []pull.Event{
+				{Status: "Downloading", Current: 2 * mb, Total: 4 * mb, HasCounts: true},
+				{Status: "Retrying in 5 seconds"},
+				{Status: "Downloading", Current: 3 * mb, Total: 4 * mb, HasCounts: true},
+				{Status: "Download complete"},
+			},
+		},
+		{
+			name: "completed by another process",
+			events: []pull.Event{
+				{Status: "Downloading", Current: 0, Total: 4 * mb, HasCounts: true},
+				{Status: "Download complete"},
+			},
+			reused: 4 * mb,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ls layerState
+			for _, ev := range tt.events {
+				ls.apply(ev, pull.DockerPhases.Classify(ev.Status))
+			}
+			if ls.current != 4*mb {
+				t.Errorf("current = %d, want %d", ls.current, 4*mb)
+			}
+			if ls.baseline != tt.reused {
+				t.Errorf("baseline = %d, want %d", ls.baseline, tt.reused)
+			}
+		})
+	}
+}
+
+func TestComputeOverall(t *testing.T) {
+	tests := []struct {
+		name          string
+		sums          layerTotals
+		n             int
+		extractWeight float64
+		prev          float64
+		pct           float64
+		allDone       bool
+		known         bool
+	}{
+		// vacuously done: a stream without layers, such as an up-to-date
+		// pull's, shows no bar
+		{name: "no layers", n: 0, allDone: true},
+		{
+			name:    "all layers already exist",
+			sums:    layerTotals{complete: 3, existing: 3},
+			n:       3,
+			pct:     1,
+			allDone: true,
+			known:   true,
+		},
+		{
+			name:  "total unknown counts finished layers",
+			sums:  layerTotals{complete: 1},
+			n:     4,
+			pct:   0.25,
+			known: true,
+		},
+		{
+			name:  "halfway",
+			sums:  layerTotals{current: 50, total: 100},
+			n:     2,
+			pct:   0.5,
+			known: true,
+		},
+		{
+			name:  "downloaded but not extracted stays below 1",
+			sums:  layerTotals{current: 100, total: 100, downloaded: 2},
+			n:     2,
+			pct:   0.99,
+			known: true,
+		},
+		{
+			name:          "extraction weighs in",
+			sums:          layerTotals{current: 100, total: 100, extracted: 50, downloaded: 2},
+			n:             2,
+			extractWeight: 0.2,
+			pct:           0.9,
+			known:         true,
+		},
+		{
+			name:    "all done",
+			sums:    layerTotals{current: 100, total: 100, extracted: 100, complete: 2},
+			n:       2,
+			pct:     1,
+			allDone: true,
+			known:   true,
+		},
+		{
+			name:  "never below the previous fraction",
+			sums:  layerTotals{current: 30, total: 100},
+			n:     2,
+			prev:  0.6,
+			pct:   0.6,
+			known: true,
+		},
+		{
+			name:  "a new layer's total does not move it back",
+			sums:  layerTotals{current: 50, total: 200, complete: 1},
+			n:     2,
+			prev:  0.5,
+			pct:   0.5,
+			known: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pct, allDone, known := computeOverall(tt.sums, tt.n, tt.extractWeight, tt.prev)
+			if math.Abs(pct-tt.pct) > 1e-9 || allDone != tt.allDone || known != tt.known {
+				t.Errorf("computeOverall() = %v, %v, %v, want %v, %v, %v", pct, allDone, known, tt.pct, tt.allDone, tt.known)
+			}
+		})
+	}
+}
+
+// TestFinalFrame checks that every successful pull with a bar shows it at
+// 100% for a frame before its final status replaces it.
+func TestFinalFrame(t *testing.T) {
+	for _, fixture := range []string{"pull-by-digest.json", "empty-progress-detail.json", "shared-layer.json"} {
+		t.Run(fixture, func(t *testing.T) {
+			m, _ := replayEvents(t, fixture, options{}).Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+			m, _ = m.Update(pullDone{idx: 0})
+			p := m.(model).pulls[0]
+			if !p.completing {
+				t.Fatal("not completing after the stream ended")
+			}
+			if p.pl.Percent != 1 {
+				t.Errorf("percent = %v on the final frame, want 1", p.pl.Percent)
+			}
+			if v := m.View(); !strings.Contains(v, "100%") {
+				t.Errorf("final frame %q shows no 100%%", v)
+			}
+			m, _ = m.Update(pullCompleted{0})
+			if p := m.(model).pulls[0]; !p.done || p.err != nil {
+				t.Errorf("done, err = %v, %v after the final frame, want success", p.done, p.err)
+			}
+		})
+	}
+}
+
+// TestExtractWeight drives two layers through downloading and extracting in
+// an interleaved order and checks the bar after each event, with extraction
+// given a share of the bar and without.
+func TestExtractWeight(t *testing.T) {
+	const mb = 1 << 20
+	steps := []pull.Event{
+		{ID: "b", Status: "Downloading", Current: 0, Total: 10 * mb, HasCounts: true},
+		{ID: "a", Status: "Downloading", Current: 5 * mb, Total: 10 * mb, HasCounts: true},
+		{ID: "a", Status: "Downloading", Current: 10 * mb, Total: 10 * mb, HasCounts: true},
+		{ID: "a", Status: "Extracting", Current: 5 * mb, Total: 10 * mb, HasCounts: true},
+		{ID: "b", Status: "Downloading", Current: 10 * mb, Total: 10 * mb, HasCounts: true},
+		{ID: "a", Status: "Pull complete"},
+		{ID: "b", Status: "Extracting", Current: 10 * mb, Total: 10 * mb, HasCounts: true},
+		{ID: "b", Status: "Pull complete"},
+	}
+	tests := []struct {
+		weight float64
+		want   []float64
+	}{
+		// extraction moves the bar on once the bytes are in
+		{0.2, []float64{0, 0.2, 0.4, 0.45, 0.85, 0.9, 0.99, 1}},
+		// without a share it sits just short of full while layers extract
+		{0, []float64{0, 0.25, 0.5, 0.5, 0.99, 0.99, 0.99, 1}},
+	}
+	for _, tt := range tests {
+		var m tea.Model = startedModel([]string{"app:1"}, options{extractWeight: tt.weight})
+		for i, ev := range steps {
+			m, _ = m.Update(progressEvent{idx: 0, Event: ev})
+			if got := m.(model).pulls[0].pl.Percent; math.Abs(got-tt.want[i]) > 1e-9 {
+				t.Errorf("weight %v, after %s %s: percent = %v, want %v", tt.weight, ev.ID, ev.Status, got, tt.want[i])
+			}
+		}
+	}
+}
+
+// TestParallelLimit checks that no more than --parallel pulls run at once,
+// and that a queued pull starts as soon as a running one ends.
+func TestParallelLimit(t *testing.T) {
+	opts := options{parallel: 3, simulate: &simulation{layers: 1, size: 1 << 20, speed: 1}}
+	m := initialModel([]string{"a:1", "b:1", "c:1", "d:1", "e:1"}, opts)
+	defer m.cancel()
+	started := func(m model) int {
+		n := 0
+		for _, p := range m.pulls {
+			if p.started {
+				n++
+			}
+		}
+		return n
+	}
+	m.Init()
+	if n := started(m); n != 3 {
+		t.Fatalf("%d pulls started, want 3", n)
+	}
+	next, _ := m.Update(pullDone{idx: 0})
+	if n := started(next.(model)); n != 4 {
+		t.Errorf("%d pulls started after one ended, want 4", n)
+	}
+}
+
+// TestFinalizeOnDownloadSlots replays a pull under --finalize-on=download
+// with --parallel 1 and checks that it gives up its slot once, when its
+// layers have downloaded, and ends then rather than again when its stream
+// drains.
+func TestFinalizeOnDownloadSlots(t *testing.T) {
+	clock := time.Unix(0, 0)
+	saved := now
+	now = func() time.Time { return clock }
+	defer func() { now = saved }()
+	opts := options{parallel: 1, finalizeOn: finalizeDownload, simulate: &simulation{layers: 1, size: 1 << 20, speed: 1}}
+	m := initialModel([]string{"python:3.12", "node:20", "redis:7"}, opts)
+	defer m.cancel()
+	m.Init()
+	f, err := os.Open(filepath.Join("testdata", "shared-layer.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var tm tea.Model = m
+	err = pull.Decode(context.Background(), f, nil, func(ev pull.Event) {
+		tm, _ = tm.Update(progressEvent{idx: 0, Event: ev})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(when string) {
+		t.Helper()
+		m := tm.(model)
+		started := 0
+		for _, p := range m.pulls {
+			if p.started {
+				started++
+			}
+		}
+		if started != 2 || m.running() != 1 {
+			t.Errorf("%s: %d started, %d running, want 2 and 1", when, started, m.running())
+		}
+	}
+	// its bar shows 100% for a frame, which ends it while extraction goes on
+	tm, _ = tm.Update(pullCompleted{0})
+	check("downloaded")
+	ended := tm.(model).pulls[0].endedAt
+	clock = clock.Add(time.Minute)
+	tm, _ = tm.Update(pullDone{idx: 0})
+	check("drained")
+	if got := tm.(model).pulls[0].endedAt; !got.Equal(ended) {
+		t.Errorf("endedAt moved from %v to %v when the stream drained", ended, got)
+	}
+	if p := tm.(model).pulls[0]; !p.done || p.draining {
+		t.Errorf("done, draining = %v, %v after the stream ended, want done", p.done, p.draining)
+	}
+}
+
+// TestFitBars checks that the bar gives up only the width of the columns
+// enabled after it, as wide as they are formatted.
+func TestFitBars(t *testing.T) {
+	tests := []struct {
+		name string
+		opts options
+		// suffix is the width reserved after the bar
+		suffix int
+	}{
+		{"nothing after the bar", options{}, 0},
+		{"eta", options{eta: true}, len("  1024.00MB/s ETA 59m59s")},
+		{"eta with compact bytes", options{eta: true, compactBytes: true}, len("  1024MB/s ETA 59m59s")},
+		{"elapsed", options{elapsed: true}, len("  59m59s")},
+		{"eta and elapsed", options{eta: true, elapsed: true}, len("  1024.00MB/s ETA 59m59s  59m59s")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.suffixWidth(); got != tt.suffix {
+				t.Errorf("suffixWidth() = %d, want %d", got, tt.suffix)
+			}
+			tt.opts.minBarWidth, tt.opts.maxBarWidth = 10, 200
+			m, _ := startedModel([]string{"node:20"}, tt.opts).Update(tea.WindowSizeMsg{Width: 120, Height: 24})
+			want := 120 - len("Pulling node:20") - len("... ") - tt.suffix
+			if got := m.(model).pulls[0].pl.Bar.Width; got != want {
+				t.Errorf("bar width = %d, want %d", got, want)
+			}
+		})
+	}
+}
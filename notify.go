@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// alerter tells the user that a run ended, for pulls left running in the
+// background: with a terminal bell, an OS notification, or both.
+type alerter struct {
+	bell   bool
+	notify bool
+}
+
+// alert signals the end of a run whose pulls completed or failed. Runs the
+// user interrupted stay silent, as they are already watching.
+func (a alerter) alert(w io.Writer, m model) {
+	if (!a.bell && !a.notify) || m.cancelReason == cancelUser || m.cancelReason == cancelSignal {
+		return
+	}
+	var done, failed int
+	for _, p := range m.pulls {
+		switch {
+		case p.err != nil:
+			failed++
+		case p.done || p.present:
+			done++
+		}
+	}
+	if done+failed == 0 {
+		return
+	}
+	if a.bell {
+		fmt.Fprint(w, "\a")
+	}
+	if a.notify {
+		title := "Pull complete"
+		if failed > 0 {
+			title = "Pull failed"
+		}
+		body := strings.Join(m.images(), ", ")
+		if len(m.pulls) > 1 {
+			body = fmt.Sprintf("%d done, %d failed", done, failed)
+		}
+		// best effort: without a notifier the bell, if any, has to do
+		_ = notify(title, body)
+	}
+}
+
+// images returns the refs being pulled, in order.
+func (m model) images() []string {
+	refs := make([]string, len(m.pulls))
+	for i, p := range m.pulls {
+		refs[i] = p.image
+	}
+	return refs
+}
+
+// notify shows an OS notification through the platform's command line
+// notifier: notify-send on Linux and the BSDs, osascript on macOS.
+func notify(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		return fmt.Errorf("notifications are not supported on %s", runtime.GOOS)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=docker pull", title, body)
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+)
+
+var (
+	hideCursorSeq = []byte("\x1b[?25l")
+	showCursorSeq = []byte("\x1b[?25h")
+)
+
+// cursorFilter drops cursor hide/show sequences from everything written to
+// the wrapped file. It keeps the file's Fd so the program still detects the
+// terminal and its size.
+type cursorFilter struct{ *os.File }
+
+func (w cursorFilter) Write(b []byte) (int, error) {
+	out := bytes.ReplaceAll(b, hideCursorSeq, nil)
+	out = bytes.ReplaceAll(out, showCursorSeq, nil)
+	if _, err := w.File.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// WriteString shadows (*os.File).WriteString so io.WriteString goes through
+// the filter as well.
+func (w cursorFilter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+)
+
+// plainInterval is the least time between two percentage lines of one image.
+const plainInterval = time.Second
+
+// maxWriteFailures is how many writes in a row may fail before the output is
+// taken to be gone, e.g. a closed pty, and the run is cancelled.
+const maxWriteFailures = 3
+
+// timestampLayout is the ISO 8601 layout of --timestamps.
+const timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// crInterval is the least time between two rewrites of the --progress=cr line.
+const crInterval = 200 * time.Millisecond
+
+// Progress modes for --progress.
+const (
+	progressAuto  = "auto"
+	progressTTY   = "tty"
+	progressPlain = "plain"
+	progressCR    = "cr"
+)
+
+// useANSI reports whether out can take the in-place renderer: it must be a
+// terminal and TERM must not be "dumb".
+func useANSI(out *os.File) bool {
+	return os.Getenv("TERM") != "dumb" && term.IsTerminal(out.Fd())
+}
+
+// plainModel wraps model for outputs without ANSI support. It renders nothing
+// in place; instead it prints a line such as "node:20: 42%" whenever an
+// image's state changes, at most once per plainInterval while in progress.
+//
+// With cr set, progress is instead rewritten on a single line using nothing
+// but carriage returns, for pagers such as `less -R` that garble erase-line
+// sequences; only final statuses get lines of their own.
+type plainModel struct {
+	model
+	out io.Writer
+	// cr is the in-place line in cr mode, and nil otherwise
+	cr *crLine
+	// last is the line last printed per pull, and when
+	last   map[*imagePull]string
+	lastAt map[*imagePull]time.Time
+	// writeFailures counts consecutive failed writes to out
+	writeFailures *int
+	// timestamps prefixes each line with the time it was printed
+	timestamps bool
+	// layerLast and layerLastAt are the --plain-layers line last printed per
+	// image and layer, and when
+	layerLast   map[string]string
+	layerLastAt map[string]time.Time
+}
+
+// crLine is the line last written in place, and when.
+type crLine struct {
+	text string
+	at   time.Time
+}
+
+func newPlainModel(m model, out io.Writer, cr bool) plainModel {
+	pm := plainModel{
+		model:         m,
+		out:           out,
+		last:          map[*imagePull]string{},
+		lastAt:        map[*imagePull]time.Time{},
+		layerLast:     map[string]string{},
+		layerLastAt:   map[string]time.Time{},
+		writeFailures: new(int),
+	}
+	if cr {
+		pm.cr = &crLine{}
+	}
+	return pm
+}
+
+func (pm plainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := pm.model.Update(msg)
+	pm.model = next.(model)
+	at := now()
+	for i, p := range pm.pulls {
+		line := p.plainStatus(pm.opts)
+		if line == "" || line == pm.last[p] {
+			continue
+		}
+		ended := p.finished() || p.cancelReason != notCancelled
+		if pm.cr != nil && !ended {
+			continue
+		}
+		if !ended && at.Sub(pm.lastAt[p]) < plainInterval {
+			continue
+		}
+		pm.rewrite("")
+		stamp := ""
+		if pm.timestamps {
+			stamp = at.Format(timestampLayout) + " "
+		}
+		pm.printf("%s%s%s: %s\n", stamp, pm.batchPrefix(i), p.image, line)
+		pm.last[p], pm.lastAt[p] = line, at
+	}
+	if pm.opts.plainLayers {
+		pm.printLayers(at)
+	}
+	if pm.cr != nil && !pm.quitting && at.Sub(pm.cr.at) >= crInterval {
+		pm.rewrite(pm.crStatus())
+		pm.cr.at = at
+	}
+	if *pm.writeFailures >= maxWriteFailures {
+		// nobody is watching any more; stop pulling rather than run on blind
+		pm.cancelAll(cancelOutput)
+	}
+	return pm, cmd
+}
+
+// printLayers prints a docker-style line for each layer whose status changed,
+// or whose progress moved at least plainInterval ago, as `docker pull` would:
+//
+//	node:20: 3f4ca61aafcd: Downloading [==>        ]  1.2MB/4.1MB
+func (pm plainModel) printLayers(at time.Time) {
+	for i, p := range pm.pulls {
+		if !p.started || p.quiet(pm.opts) {
+			continue
+		}
+		for _, l := range p.layers.snapshot() {
+			if l.status == "" {
+				// known from --prefetch-manifest only
+				continue
+			}
+			key := p.image + "/" + l.id
+			line := l.id + ": " + pm.opts.layerStatus(l.layerState)
+			// a line that only moved the bar is throttled like the image's
+			moved := false
+			if bar := l.dockerProgress(pm.opts); bar != "" {
+				moved = strings.HasPrefix(pm.layerLast[key], line+" ")
+				line += " " + bar
+			}
+			if line == pm.layerLast[key] || (moved && (pm.cr != nil || at.Sub(pm.layerLastAt[key]) < plainInterval)) {
+				continue
+			}
+			pm.rewrite("")
+			stamp := ""
+			if pm.timestamps {
+				stamp = at.Format(timestampLayout) + " "
+			}
+			pm.printf("%s%s%s: %s\n", stamp, pm.batchPrefix(i), p.image, line)
+			pm.layerLast[key], pm.layerLastAt[key] = line, at
+		}
+	}
+}
+
+// dockerBarWidth is the width of the bar docker draws between brackets.
+const dockerBarWidth = 50
+
+// dockerProgress returns the daemon's rendering of l's progress, or rebuilds
+// one like it from the counts when the daemon sent none.
+func (l layerEntry) dockerProgress(opts options) string {
+	if l.progress != "" {
+		return l.progress
+	}
+	var cur, total int64
+	switch {
+	case l.phase == pull.PhaseDownloading:
+		cur, total = l.current, l.total
+	case l.phase == pull.PhaseExtracting:
+		cur, total = l.extractCurrent, l.extractTotal
+	}
+	if total <= 0 {
+		return ""
+	}
+	n := min(max(int(dockerBarWidth*cur/total), 1), dockerBarWidth)
+	return fmt.Sprintf("[%s>%s]  %s/%s", strings.Repeat("=", n-1), strings.Repeat(" ", dockerBarWidth-n), opts.bytes(cur), opts.bytes(total))
+}
+
+// printf writes to out, keeping count of consecutive failures.
+func (pm plainModel) printf(format string, a ...any) {
+	if _, err := fmt.Fprintf(pm.out, format, a...); err != nil {
+		*pm.writeFailures++
+		return
+	}
+	*pm.writeFailures = 0
+}
+
+// rewrite replaces the in-place line with s, blanking what is left of the
+// previous one since the line cannot be erased.
+func (pm plainModel) rewrite(s string) {
+	if pm.cr == nil || s == pm.cr.text {
+		return
+	}
+	pad := max(runewidth.StringWidth(pm.cr.text)-runewidth.StringWidth(s), 0)
+	pm.printf("\r%s%s", s, strings.Repeat(" ", pad))
+	if s == "" {
+		pm.printf("\r")
+	}
+	pm.cr.text = s
+}
+
+// crStatus is the in-place line of cr mode: the running image's status, or
+// the overall progress of a batch.
+func (pm plainModel) crStatus() string {
+	if !pm.isBatch() {
+		p := pm.pulls[0]
+		if p.finished() {
+			return ""
+		}
+		return fmt.Sprintf("%s: %s", p.image, p.plainStatus(pm.opts))
+	}
+	done, _, frac := pm.overall()
+	if done == len(pm.pulls) {
+		return ""
+	}
+	return pm.opts.sprintf("%d/%d finished, %.0f%% overall", done, len(pm.pulls), 100*frac)
+}
+
+func (pm plainModel) View() string {
+	return ""
+}
+
+// plainStatus describes p for the plain renderer, or returns "" when there is
+// nothing to report yet.
+func (p *imagePull) plainStatus(opts options) string {
+	if !p.started || p.quiet(opts) {
+		return ""
+	}
+	if s := p.outcome(opts); s != "" {
+		return s
+	}
+	if p.coolingDown() {
+		return "rate limited, retrying in " + humanDuration(p.cooldown.wait)
+	}
+	if !p.barVisible(opts) {
+		return "pulling"
+	}
+	return opts.sprintf("%d%%", int(p.pl.Percent*100))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// failingWriter fails every write, as a closed pipe or terminal does.
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+// TestPlainOutputGone checks that a run whose output keeps failing cancels
+// its pulls with cancelOutput rather than pulling on blind.
+func TestPlainOutputGone(t *testing.T) {
+	w := &failingWriter{}
+	var m tea.Model = newPlainModel(startedModel([]string{"node:20", "redis:7"}, options{plainLayers: true}), w, false)
+	for i := 0; i < maxWriteFailures; i++ {
+		m, _ = m.Update(progressEvent{idx: 0, Event: pull.Event{ID: fmt.Sprintf("layer%d", i), Status: "Waiting"}})
+	}
+	pm := m.(plainModel)
+	if w.writes < maxWriteFailures {
+		t.Fatalf("%d writes, want at least %d", w.writes, maxWriteFailures)
+	}
+	if pm.cancelReason != cancelOutput {
+		t.Errorf("cancelReason = %v, want %v", pm.cancelReason, cancelOutput)
+	}
+	for _, p := range pm.pulls {
+		if p.cancelReason != cancelOutput {
+			t.Errorf("%s: cancelReason = %v, want %v", p.image, p.cancelReason, cancelOutput)
+		}
+	}
+}
+
+// TestPlainOutputRecovers checks that a failure or two followed by a write
+// that succeeds do not cancel the run.
+func TestPlainOutputRecovers(t *testing.T) {
+	pm := newPlainModel(startedModel([]string{"node:20"}, options{}), &failingWriter{}, false)
+	for i := 0; i < maxWriteFailures-1; i++ {
+		pm.printf("line\n")
+	}
+	pm.out = io.Discard
+	pm.printf("line\n")
+	if *pm.writeFailures != 0 {
+		t.Errorf("writeFailures = %d after a good write, want 0", *pm.writeFailures)
+	}
+}
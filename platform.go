@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// joinPlatform formats a platform as docker does, e.g. linux/arm64/v8.
+func joinPlatform(os, arch, variant string) string {
+	if os == "" || arch == "" {
+		return ""
+	}
+	p := os + "/" + arch
+	if variant != "" {
+		p += "/" + variant
+	}
+	return p
+}
+
+// lookupHostPlatform records the platform of the daemon, which is what
+// images run on without emulation.
+func (r *imageResult) lookupHostPlatform(ctx context.Context, cli *client.Client) {
+	if v, err := cli.ServerVersion(ctx); err == nil {
+		r.hostPlatform = joinPlatform(v.Os, v.Arch, "")
+	}
+}
+
+// printPlatformReport tells which platform each pulled image is for, and
+// warns about those that will run emulated, e.g. an amd64 image pulled on
+// Apple Silicon.
+func printPlatformReport(w io.Writer, pulls []*imagePull) {
+	for _, p := range pulls {
+		if !p.done || p.cancelReason != notCancelled || p.platform == "" {
+			continue
+		}
+		fmt.Fprintf(w, "%s: pulled %s\n", p.image, p.platform)
+		if p.hostPlatform != "" && !samePlatform(p.platform, p.hostPlatform) {
+			fmt.Fprintf(w, "Warning: %s is %s but the daemon runs %s; containers will be emulated\n", p.image, p.platform, p.hostPlatform)
+		}
+	}
+}
+
+// samePlatform compares os/arch, ignoring the variant the daemon does not
+// report.
+func samePlatform(image, host string) bool {
+	return image == host || strings.HasPrefix(image, host+"/")
+}
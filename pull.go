@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type progressEvent struct {
+	idx int
+	pull.Event
+}
+
+// imageResult describes the local image once a pull has succeeded.
+type imageResult struct {
+	// imageID and size are empty when the image could not be inspected
+	imageID string
+	size    int64
+	// repoTags are the image's local tags, for naming a pull by digest
+	repoTags []string
+	// platform is the image's os/arch[/variant]; hostPlatform is the
+	// daemon's, and only looked up for --image-platform-report
+	platform     string
+	hostPlatform string
+	// tagged and tagErr report --tag, apart from the pull's own outcome
+	tagged bool
+	tagErr error
+}
+
+// pullDone reports the end of a pull.
+type pullDone struct {
+	idx int
+	imageResult
+}
+
+// pullPresent reports that the image was found locally and the pull policy
+// allowed skipping the pull.
+type pullPresent struct {
+	idx int
+	imageResult
+}
+
+// Pull policies mirror Kubernetes' imagePullPolicy.
+const (
+	pullAlways  = "always"
+	pullMissing = "missing"
+	pullNever   = "never"
+)
+
+const (
+	// defaultRateLimitCooldown is waited out when a rate limit names no
+	// cooldown of its own.
+	defaultRateLimitCooldown = time.Minute
+	// maxRateLimitRetries bounds the retries of --respect-rate-limit.
+	maxRateLimitRetries = 3
+	// prefetchTimeout bounds the --prefetch-manifest round trips, which
+	// must not hold up the pull for long.
+	prefetchTimeout = 5 * time.Second
+)
+
+// pullCooldown reports that a rate-limited pull waits until until before
+// retrying; a zero until means it has resumed.
+type pullCooldown struct {
+	idx   int
+	wait  time.Duration
+	until time.Time
+}
+
+// expectedLayers carries the layers of the manifest about to be pulled, for
+// --prefetch-manifest.
+type expectedLayers struct {
+	idx    int
+	layers []ocispec.Descriptor
+}
+
+type pullErr struct {
+	idx int
+	err error
+}
+
+func waitForMsg(idx int, ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return pullDone{idx: idx}
+		}
+		return msg
+	}
+}
+
+// pullPanic carries a panic out of pull idx's goroutine, which the program
+// does not guard. The pull fails with it, and its stack is printed once the
+// terminal has been restored.
+type pullPanic struct {
+	idx   int
+	value any
+	stack []byte
+}
+
+func pullImage(ctx context.Context, idx int, img string, opts options, out chan<- tea.Msg) {
+	defer close(out)
+	defer func() {
+		if r := recover(); r != nil {
+			out <- pullPanic{idx, r, debug.Stack()}
+		}
+	}()
+	filters := opts.filters
+	if pace := newPacer(opts.replaySpeed); pace != nil {
+		filters = append([]pull.Filter{pace.filter(ctx)}, filters...)
+	}
+	emit := func(ev pull.Event) {
+		out <- progressEvent{idx: idx, Event: ev}
+	}
+
+	var (
+		cli *client.Client
+		err error
+	)
+	switch {
+	case opts.simulate != nil:
+		r, w := io.Pipe()
+		// closing the reader, even on a panic, unblocks the stream's writes
+		defer r.Close()
+		go opts.simulate.stream(ctx, img, w)
+		err = pull.Decode(ctx, r, filters, emit)
+	case opts.fromStdin:
+		var in io.Reader
+		if in, err = replayInput(os.Stdin); err == nil {
+			err = pull.Decode(ctx, in, filters, emit)
+		}
+	default:
+		cli, err = client.NewClientWithOpts(opts.clientOpts...)
+		if err != nil {
+			out <- pullErr{idx, err}
+			return
+		}
+		defer cli.Close()
+
+		if opts.pullPolicy == pullMissing || opts.pullPolicy == pullNever {
+			info, err := cli.ImageInspect(ctx, img)
+			switch {
+			case err == nil:
+				var res imageResult
+				res.inspected(info)
+				res.tag(ctx, cli, img, opts.tag)
+				if opts.platformReport {
+					res.lookupHostPlatform(ctx, cli)
+				}
+				out <- pullPresent{idx, res}
+				return
+			case !client.IsErrNotFound(err):
+				out <- pullErr{idx, err}
+				return
+			case opts.pullPolicy == pullNever:
+				out <- pullErr{idx, fmt.Errorf("image %s is not present locally and pull policy is %s", img, pullNever)}
+				return
+			}
+		}
+		if opts.prefetchManifest {
+			prefetchManifest(ctx, idx, cli, img, out)
+		}
+		err = pullRespectingRateLimit(ctx, idx, cli, img, opts, filters, emit, out)
+	}
+	if err != nil {
+		if !isCanceled(err) {
+			out <- pullErr{idx, err}
+		}
+		return
+	}
+	done := pullDone{idx: idx}
+	if cli != nil {
+		// The pull already succeeded; a failed inspect only loses the details.
+		if info, err := cli.ImageInspect(ctx, img); err == nil {
+			done.inspected(info)
+		}
+		done.tag(ctx, cli, img, opts.tag)
+		if opts.platformReport {
+			done.lookupHostPlatform(ctx, cli)
+		}
+	}
+	out <- done
+}
+
+// prefetchManifest fetches img's manifest for the daemon's platform and
+// sends its layers ahead of the pull, so that the bar starts from the full
+// size of the image rather than growing as layers report their totals. It is
+// best effort: without the manifest the pull goes on as usual.
+func prefetchManifest(ctx context.Context, idx int, cli *client.Client, img string, out chan<- tea.Msg) {
+	ctx, cancel := context.WithTimeout(ctx, prefetchTimeout)
+	defer cancel()
+	osName, arch := "linux", runtime.GOARCH
+	if v, err := cli.ServerVersion(ctx); err == nil {
+		osName, arch = v.Os, v.Arch
+	}
+	m, err := fetchManifest(ctx, img, osName, arch)
+	if err != nil || len(m.Layers) == 0 {
+		return
+	}
+	out <- expectedLayers{idx, m.Layers}
+}
+
+// pullRespectingRateLimit runs pull.Pull, and under --respect-rate-limit
+// waits out a rate limit and tries again, up to maxRateLimitRetries times.
+// The pull line shows the cooldown while it waits.
+func pullRespectingRateLimit(ctx context.Context, idx int, cli pull.Client, img string, opts options, filters []pull.Filter, emit func(pull.Event), out chan<- tea.Msg) error {
+	pullOpts := pull.Options{Filters: filters}
+	pullOpts.PullOptions.RegistryAuth = registryAuth(img)
+	if opts.promptAuth {
+		pullOpts.PullOptions.PrivilegeFunc = privilegeFunc(idx, img, out)
+	}
+	for attempt := 0; ; attempt++ {
+		err := pull.Pull(ctx, cli, img, pullOpts, emit)
+		if err == nil || !opts.respectRateLimit || attempt == maxRateLimitRetries || pull.Classify(err) != pull.KindRateLimit {
+			return err
+		}
+		wait, ok := pull.RetryAfter(err)
+		if !ok {
+			wait = defaultRateLimitCooldown
+		}
+		out <- pullCooldown{idx, wait, now().Add(wait)}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		out <- pullCooldown{idx: idx}
+	}
+}
+
+// inspected records the details of the local image.
+func (r *imageResult) inspected(info image.InspectResponse) {
+	r.imageID = info.ID
+	r.size = info.Size
+	r.repoTags = info.RepoTags
+	r.platform = joinPlatform(info.Os, info.Architecture, info.Variant)
+}
+
+// tag applies --tag to the pulled image, if set.
+func (r *imageResult) tag(ctx context.Context, cli *client.Client, img, target string) {
+	if target == "" {
+		return
+	}
+	r.tagErr = cli.ImageTag(ctx, img, target)
+	r.tagged = r.tagErr == nil
+}
+
+// isCanceled reports whether err stems from the pull context being cancelled.
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || (err != nil && strings.Contains(strings.ToLower(err.Error()), "context canceled"))
+}
@@ -0,0 +1,123 @@
+// Package pull decodes the JSON progress stream returned by the Docker
+// daemon's image pull endpoint into typed events.
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Event is one progress message of a pull stream. ID is the layer ID, or
+// empty for image-level messages such as "Pulling from" or "Digest:".
+type Event struct {
+	ID      string
+	Status  string
+	Current int64
+	Total   int64
+	// HasCounts is set when the message carried byte counts. Statuses such as
+	// "Verifying Checksum" come with an empty progressDetail, or one holding
+	// only {"hidecounts":true}; their zero Current and Total mean nothing.
+	HasCounts bool
+	// Progress is the daemon's own rendering of the counts, e.g.
+	// "[==>      ]  1.2MB/4.1MB", or empty when it sent none.
+	Progress string
+}
+
+// Filter sees every raw stream message before it is decoded into an Event.
+// It may rewrite the message in place; returning false drops it.
+type Filter func(msg map[string]any) bool
+
+// Error is a failure reported by the daemon inside the pull stream.
+type Error struct {
+	Message string
+	// Code is the errorDetail code, or 0 when the daemon sent none.
+	Code int
+}
+
+func (e *Error) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+	}
+	return e.Message
+}
+
+// Decode reads a pull stream from r, passing each message through filters in
+// order and then to emit, until the stream ends. It returns nil at the end of
+// the stream, an *Error if the daemon reported one, and ctx.Err() if ctx is
+// done between messages.
+func Decode(ctx context.Context, r io.Reader, filters []Filter, emit func(Event)) error {
+	dec := json.NewDecoder(r)
+	for {
+		var e map[string]any
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !applyFilters(filters, e) {
+			continue
+		}
+		if err := parseError(e); err != nil {
+			return err
+		}
+		emit(parseEvent(e))
+	}
+}
+
+func applyFilters(filters []Filter, e map[string]any) bool {
+	for _, f := range filters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseEvent(e map[string]any) Event {
+	var ev Event
+	if s, ok := e["id"].(string); ok {
+		ev.ID = s
+	}
+	if s, ok := e["status"].(string); ok {
+		ev.Status = s
+	}
+	if s, ok := e["progress"].(string); ok {
+		ev.Progress = s
+	}
+	if pd, ok := e["progressDetail"].(map[string]any); ok {
+		c, hasCurrent := pd["current"].(float64)
+		t, hasTotal := pd["total"].(float64)
+		hide, _ := pd["hidecounts"].(bool)
+		ev.Current, ev.Total = int64(c), int64(t)
+		ev.HasCounts = (hasCurrent || hasTotal) && !hide
+	}
+	return ev
+}
+
+// parseError returns the error carried by a stream message, preferring
+// errorDetail over the top-level error string, or nil if there is none.
+func parseError(e map[string]any) error {
+	perr := &Error{}
+	if s, ok := e["error"].(string); ok {
+		perr.Message = s
+	}
+	if d, ok := e["errorDetail"].(map[string]any); ok {
+		if s, ok := d["message"].(string); ok && s != "" {
+			perr.Message = s
+		}
+		if c, ok := d["code"].(float64); ok {
+			perr.Code = int(c)
+		}
+	}
+	if perr.Message == "" {
+		return nil
+	}
+	return perr
+}
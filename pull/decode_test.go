@@ -0,0 +1,76 @@
+package pull
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   *Error
+		// events is how many events were emitted before the error
+		events int
+	}{
+		{
+			name:   "errorDetail with a code",
+			stream: `{"status":"Pulling from library/node","id":"20"}` + "\n" + `{"errorDetail":{"message":"toomanyrequests: rate limit","code":429},"error":"toomanyrequests"}`,
+			want:   &Error{Message: "toomanyrequests: rate limit", Code: 429},
+			events: 1,
+		},
+		{
+			name:   "errorDetail wins over error",
+			stream: `{"errorDetail":{"message":"manifest unknown"},"error":"not found"}`,
+			want:   &Error{Message: "manifest unknown"},
+		},
+		{
+			name:   "empty errorDetail message falls back to error",
+			stream: `{"errorDetail":{"message":"","code":1},"error":"unauthorized"}`,
+			want:   &Error{Message: "unauthorized", Code: 1},
+		},
+		{
+			name:   "error alone",
+			stream: `{"error":"no space left on device"}`,
+			want:   &Error{Message: "no space left on device"},
+		},
+		{
+			name:   "no error",
+			stream: `{"status":"Digest: sha256:abc"}` + "\n" + `{"status":"Status: Image is up to date for node:20"}`,
+			events: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := 0
+			err := Decode(context.Background(), strings.NewReader(tt.stream), nil, func(Event) { events++ })
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Decode() = %v, want nil", err)
+				}
+			} else {
+				var perr *Error
+				if !errors.As(err, &perr) {
+					t.Fatalf("Decode() = %v, want an *Error", err)
+				}
+				if *perr != *tt.want {
+					t.Errorf("Decode() = %+v, want %+v", *perr, *tt.want)
+				}
+			}
+			if events != tt.events {
+				t.Errorf("%d events emitted, want %d", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	if got, want := (&Error{Message: "denied", Code: 403}).Error(), "denied (code 403)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if got, want := (&Error{Message: "denied"}).Error(), "denied"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
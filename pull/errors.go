@@ -0,0 +1,116 @@
+package pull
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// Kind classifies why a pull failed.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	// KindAuth means the registry refused access, e.g. a private repository.
+	KindAuth
+	// KindNotFound means the repository, tag or manifest does not exist.
+	KindNotFound
+	// KindRateLimit means the registry throttled the pull.
+	KindRateLimit
+	// KindNetwork means the daemon or registry could not be reached in time.
+	KindNetwork
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindAuth:
+		return "auth"
+	case KindNotFound:
+		return "not found"
+	case KindRateLimit:
+		return "rate limit"
+	case KindNetwork:
+		return "network"
+	}
+	return "unknown"
+}
+
+// Hint returns guidance for resolving a failure of kind k, or "" if there is
+// none.
+func (k Kind) Hint() string {
+	switch k {
+	case KindAuth:
+		return "the repository may be private or misspelled; check the name or run `docker login <registry>`"
+	case KindNotFound:
+		return "check that the image name and tag exist in the registry"
+	case KindRateLimit:
+		return "the registry is rate limiting pulls; wait before retrying or run `docker login` for a higher limit"
+	case KindNetwork:
+		return "check that the Docker daemon is running and the registry is reachable"
+	}
+	return ""
+}
+
+// Classify returns the kind of a pull error, whether it came from the initial
+// request or from the stream.
+func Classify(err error) Kind {
+	if err == nil {
+		return KindUnknown
+	}
+	var netErr net.Error
+	switch {
+	case errdefs.IsUnauthorized(err), errdefs.IsForbidden(err):
+		return KindAuth
+	case errdefs.IsNotFound(err):
+		return KindNotFound
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return KindNetwork
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "toomanyrequests", "rate limit"):
+		return KindRateLimit
+	case containsAny(msg, "pull access denied", "unauthorized", "authentication required", "denied: requested access"):
+		return KindAuth
+	case containsAny(msg, "manifest unknown", "not found", "no such image", "name unknown"):
+		return KindNotFound
+	case containsAny(msg, "cannot connect to the docker daemon", "connection refused", "no such host", "i/o timeout", "tls handshake timeout", "ssh connection"):
+		return KindNetwork
+	}
+	return KindUnknown
+}
+
+var retryAfterRe = regexp.MustCompile(`(?i)retry[- ]after:?\s*(\d+)`)
+
+// RetryAfter returns the cooldown a rate limit error asks for, when its message
+// carries one such as "Retry-After: 60". The daemon usually passes on only the
+// registry's message, which for Docker Hub names no cooldown.
+func RetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	m := retryAfterRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
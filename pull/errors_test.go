@@ -0,0 +1,80 @@
+package pull
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+)
+
+// fakeClient answers ImagePull with err, or else with stream.
+type fakeClient struct {
+	err    error
+	stream string
+}
+
+func (c fakeClient) ImagePull(context.Context, string, image.PullOptions) (io.ReadCloser, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return io.NopCloser(strings.NewReader(c.stream)), nil
+}
+
+func TestClassifyInitialErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cli  fakeClient
+		want Kind
+	}{
+		{"typed unauthorized", fakeClient{err: errdefs.Unauthorized(errors.New("authentication required"))}, KindAuth},
+		{"typed forbidden", fakeClient{err: errdefs.Forbidden(errors.New("denied"))}, KindAuth},
+		{"typed not found", fakeClient{err: errdefs.NotFound(errors.New("manifest for node:99 not found"))}, KindNotFound},
+		{"pull access denied", fakeClient{err: errors.New("Error response from daemon: pull access denied for acme/private, repository does not exist or may require 'docker login': denied: requested access to the resource is denied")}, KindAuth},
+		{"manifest unknown", fakeClient{err: errors.New("Error response from daemon: manifest unknown: manifest unknown")}, KindNotFound},
+		{"rate limit", fakeClient{err: errors.New("Error response from daemon: toomanyrequests: You have reached your pull rate limit.")}, KindRateLimit},
+		{"daemon down", fakeClient{err: errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?")}, KindNetwork},
+		{"deadline", fakeClient{err: fmt.Errorf("pull: %w", context.DeadlineExceeded)}, KindNetwork},
+		{"in the stream", fakeClient{stream: `{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}`}, KindNotFound},
+		{"unrecognised", fakeClient{err: errors.New("something odd")}, KindUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Pull(context.Background(), tt.cli, "node:20", Options{}, func(Event) {})
+			if err == nil {
+				t.Fatal("Pull() = nil, want an error")
+			}
+			kind := Classify(err)
+			if kind != tt.want {
+				t.Errorf("Classify(%q) = %v, want %v", err, kind, tt.want)
+			}
+			if hint := kind.Hint(); (hint != "") != (tt.want != KindUnknown) {
+				t.Errorf("Hint() = %q for %v", hint, kind)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		err  error
+		want time.Duration
+		ok   bool
+	}{
+		{errors.New("toomanyrequests: slow down, Retry-After: 60"), time.Minute, true},
+		{errors.New("toomanyrequests: retry after 5"), 5 * time.Second, true},
+		{errors.New("toomanyrequests: You have reached your pull rate limit."), 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := RetryAfter(tt.err)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("RetryAfter(%v) = %v, %v, want %v, %v", tt.err, got, ok, tt.want, tt.ok)
+		}
+	}
+}
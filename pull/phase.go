@@ -0,0 +1,86 @@
+package pull
+
+import "strings"
+
+// Phase is the stage of a layer, or of the whole image, that a status of the
+// pull stream reports.
+type Phase int
+
+const (
+	// PhaseUnknown is a status the classifier does not recognise.
+	PhaseUnknown Phase = iota
+	// PhaseResolving is the image-level header, e.g. "Pulling from library/node".
+	PhaseResolving
+	// PhaseWaiting is a layer queued for download: "Pulling fs layer" or
+	// "Waiting".
+	PhaseWaiting
+	// PhaseRetrying is a layer download that failed and is retried, e.g.
+	// "Retrying in 5 seconds".
+	PhaseRetrying
+	PhaseDownloading
+	PhaseVerifying
+	// PhaseDownloaded is a layer downloaded but not yet extracted.
+	PhaseDownloaded
+	PhaseExtracting
+	// PhaseComplete is a layer downloaded and extracted.
+	PhaseComplete
+	// PhaseExists is a layer the daemon already had.
+	PhaseExists
+	// PhaseUpToDate is the image-level result of a pull that found nothing new.
+	PhaseUpToDate
+	// PhaseUpdated is the image-level result of a pull that fetched content.
+	PhaseUpdated
+)
+
+// Done reports whether a layer in phase p needs no more downloading.
+func (p Phase) Done() bool {
+	return p == PhaseDownloaded || p == PhaseComplete || p == PhaseExists
+}
+
+// Present reports whether a layer in phase p is fully pulled.
+func (p Phase) Present() bool {
+	return p == PhaseComplete || p == PhaseExists
+}
+
+// PhaseClassifier maps the statuses of a progress stream to phases. Streams
+// other than the Docker daemon's can be rendered by classifying their own
+// statuses.
+type PhaseClassifier interface {
+	Classify(status string) Phase
+}
+
+// DockerPhases classifies the statuses of the Docker daemon's pull stream.
+var DockerPhases PhaseClassifier = dockerPhases{}
+
+type dockerPhases struct{}
+
+func (dockerPhases) Classify(status string) Phase {
+	switch status {
+	case "Pulling fs layer", "Waiting":
+		return PhaseWaiting
+	case "Downloading":
+		return PhaseDownloading
+	case "Verifying Checksum":
+		return PhaseVerifying
+	case "Download complete":
+		return PhaseDownloaded
+	case "Extracting":
+		return PhaseExtracting
+	case "Pull complete":
+		return PhaseComplete
+	case "Already exists":
+		return PhaseExists
+	}
+	lower := strings.ToLower(status)
+	switch {
+	case strings.HasPrefix(status, "Retrying"):
+		return PhaseRetrying
+	case strings.Contains(lower, "pulling from"):
+		return PhaseResolving
+	case strings.Contains(lower, "image is up to date"):
+		return PhaseUpToDate
+	case strings.Contains(lower, "downloaded newer image"):
+		return PhaseUpdated
+	}
+	return PhaseUnknown
+}
@@ -0,0 +1,127 @@
+package pull
+
+import (
+	"context"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// Client is the part of the Docker API client used to pull images.
+// *client.Client satisfies it.
+type Client interface {
+	ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error)
+}
+
+// Options configures a pull. The zero value is ready to use.
+type Options struct {
+	// Filters see every raw stream message before it becomes an Event.
+	Filters []Filter
+	// OnLayerChange, if set, is called whenever a layer's status changes,
+	// e.g. from "Downloading" to "Download complete", just before the Event
+	// carrying the new status is emitted. Repeated progress of one status does
+	// not call it.
+	OnLayerChange func(LayerChange)
+	// PullOptions is passed to ImagePull as is, for settings such as
+	// RegistryAuth, Platform or PrivilegeFunc.
+	PullOptions image.PullOptions
+}
+
+// LayerChange is a layer moving to a new status.
+type LayerChange struct {
+	ID string
+	// From is the previous status, or "" for the layer's first message.
+	From   string
+	Status string
+}
+
+// Pull pulls ref through cli, calling emit for each progress event, and
+// returns once the pull has ended. See Decode for the errors it returns.
+func Pull(ctx context.Context, cli Client, ref string, opts Options, emit func(Event)) error {
+	rc, err := cli.ImagePull(ctx, ref, opts.PullOptions)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if opts.OnLayerChange != nil {
+		emit = trackLayers(opts.OnLayerChange, emit)
+	}
+	return Decode(ctx, rc, opts.Filters, emit)
+}
+
+// trackLayers wraps emit to call onChange for each layer status change. The
+// "Pulling from" header carries the tag as its ID and is not a layer.
+func trackLayers(onChange func(LayerChange), emit func(Event)) func(Event) {
+	status := map[string]string{}
+	return func(ev Event) {
+		isLayer := ev.ID != "" && ev.Status != "" && !strings.HasPrefix(ev.Status, "Pulling from ")
+		if isLayer && status[ev.ID] != ev.Status {
+			onChange(LayerChange{ID: ev.ID, From: status[ev.ID], Status: ev.Status})
+			status[ev.ID] = ev.Status
+		}
+		emit(ev)
+	}
+}
+
+// Events runs Pull and yields its events as they arrive, for use with range:
+//
+//	for ev, err := range pull.Events(ctx, cli, "node:20", pull.Options{}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(ev.ID, ev.Status)
+//	}
+//
+// A failed pull ends with a single zero Event paired with the error. Breaking
+// out of the loop cancels the pull.
+func Events(ctx context.Context, cli Client, ref string, opts Options) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stopped := false
+		err := Pull(ctx, cli, ref, opts, func(ev Event) {
+			if !stopped && !yield(ev, nil) {
+				stopped = true
+				cancel()
+			}
+		})
+		if err != nil && !stopped {
+			yield(Event{}, err)
+		}
+	}
+}
+
+// Puller is a pull running in the background, as returned by Start.
+type Puller struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Start runs Pull in a new goroutine. The pull can be stopped with Cancel
+// without cancelling ctx; emit is called from that goroutine.
+func Start(ctx context.Context, cli Client, ref string, opts Options, emit func(Event)) *Puller {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Puller{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		defer cancel()
+		p.err = Pull(ctx, cli, ref, opts, emit)
+	}()
+	return p
+}
+
+// Cancel stops the pull. It is safe to call more than once and from any
+// goroutine.
+func (p *Puller) Cancel() {
+	p.cancel()
+}
+
+// Wait blocks until the pull has ended and returns its error. After Cancel
+// the error is typically context.Canceled.
+func (p *Puller) Wait() error {
+	<-p.done
+	return p.err
+}
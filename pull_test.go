@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestPullImagePanic makes a pull panic inside its goroutine, through a
+// filter that blows up on the first message, and checks that the model
+// records it as a failed pull rather than the program dying with it.
+func TestPullImagePanic(t *testing.T) {
+	opts := options{
+		simulate: &simulation{layers: 1, size: 1 << 20, speed: 1 << 30},
+		filters:  []pull.Filter{func(map[string]any) bool { panic("boom") }},
+	}
+	var m tea.Model = startedModel([]string{"node:20", "redis:7"}, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan tea.Msg, 16)
+	go pullImage(ctx, 0, "node:20", opts, out)
+	for msg := range out {
+		m, _ = m.Update(msg)
+	}
+
+	final := m.(model)
+	p := final.pulls[0]
+	if p.err == nil || !strings.Contains(p.err.Error(), "boom") {
+		t.Fatalf("err = %v, want the panic", p.err)
+	}
+	if got := p.reportResult(); got != "failed" {
+		t.Errorf("reportResult() = %q, want failed", got)
+	}
+	if final.crashed == nil || len(final.crashed.stack) == 0 {
+		t.Error("the panic's stack was not kept")
+	}
+	if final.pulls[1].finished() {
+		t.Error("the other pull ended with the panicking one")
+	}
+	if got := exitCode(final.pulls); got != exitFailure {
+		t.Errorf("exitCode() = %d, want %d", got, exitFailure)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// defaultSmoothing is the EWMA weight given to each new rate sample.
+	defaultSmoothing = 0.3
+	// minSampleInterval keeps bursts of events from producing huge
+	// instantaneous rates over tiny time slices.
+	minSampleInterval = 250 * time.Millisecond
+	// minETAStep is the least the displayed ETA may move per sample.
+	minETAStep = time.Second
+	// rateTickInterval is how often rates are sampled while no bytes
+	// arrive, so that a stalled transfer shows its rate falling.
+	rateTickInterval = time.Second
+	// rateQuietAfter is how long a transfer may move no bytes before its
+	// rate and ETA are dropped as unknown.
+	rateQuietAfter = 5 * time.Second
+)
+
+// rateTickMsg samples the rates of the running pulls.
+type rateTickMsg struct{}
+
+func tickRates() tea.Cmd {
+	return tea.Tick(rateTickInterval, func(time.Time) tea.Msg { return rateTickMsg{} })
+}
+
+// rateMeter smooths a growing byte count into a transfer rate using an
+// exponentially weighted moving average, and derives an ETA whose changes are
+// damped so it does not jump wildly between samples.
+type rateMeter struct {
+	// alpha is the smoothing factor in (0,1]; higher reacts faster
+	alpha  float64
+	lastAt time.Time
+	lastN  int64
+	// movedAt is when the count last grew
+	movedAt time.Time
+	// rate is the smoothed rate in bytes per second, 0 until known
+	rate float64
+	// eta is the damped time left, 0 until known
+	eta time.Duration
+	// history holds the latest instantaneous rates in a ring, next is where
+	// the next one goes and filled whether it has wrapped
+	history [sparklineSamples]float64
+	next    int
+	filled  bool
+}
+
+func newRateMeter(alpha float64) *rateMeter {
+	if alpha <= 0 || alpha > 1 {
+		alpha = defaultSmoothing
+	}
+	return &rateMeter{alpha: alpha}
+}
+
+// sample records that n of total bytes have been transferred at now. A total
+// of 0 means it is unknown and leaves the ETA unset. Sampling the same n
+// again, as the rate tick does, decays the rate, and after rateQuietAfter
+// without progress drops it.
+func (r *rateMeter) sample(now time.Time, n, total int64) {
+	if r.lastAt.IsZero() || n < r.lastN {
+		// first sample, or the counter went backwards: rebase
+		r.lastAt, r.lastN, r.movedAt = now, n, now
+		return
+	}
+	dt := now.Sub(r.lastAt)
+	if dt < minSampleInterval {
+		return
+	}
+	if n > r.lastN {
+		r.movedAt = now
+	}
+	inst := float64(n-r.lastN) / dt.Seconds()
+	r.history[r.next] = inst
+	r.next = (r.next + 1) % len(r.history)
+	r.filled = r.filled || r.next == 0
+	switch {
+	case now.Sub(r.movedAt) >= rateQuietAfter:
+		r.rate = 0
+	case r.rate == 0:
+		r.rate = inst
+	default:
+		r.rate = r.alpha*inst + (1-r.alpha)*r.rate
+	}
+	r.lastAt, r.lastN = now, n
+	r.updateETA(total - n)
+}
+
+// updateETA moves the ETA towards the time left for remaining bytes at the
+// current rate, by at most a quarter of its value (or minETAStep) per sample.
+func (r *rateMeter) updateETA(remaining int64) {
+	if r.rate <= 0 || remaining <= 0 {
+		r.eta = 0
+		return
+	}
+	target := time.Duration(float64(remaining) / r.rate * float64(time.Second))
+	if r.eta == 0 {
+		r.eta = target
+		return
+	}
+	step := max(r.eta/4, minETAStep)
+	r.eta = min(max(target, r.eta-step), r.eta+step)
+}
+
+// recent returns the latest instantaneous rates, oldest first.
+func (r *rateMeter) recent() []float64 {
+	if !r.filled {
+		return r.history[:r.next]
+	}
+	return append(r.history[r.next:], r.history[:r.next]...)
+}
+
+// layerRate is the smoothed rate of one layer's download or extraction. It is
+// a plain value, so layer states stay safe to copy out of their set.
+type layerRate struct {
+	at time.Time
+	n  int64
+	// movedAt is when the count last grew
+	movedAt time.Time
+	// bps is the smoothed rate in bytes per second, 0 until known
+	bps float64
+}
+
+// sample records that the layer's counter stood at n at now, smoothing with
+// the EWMA factor alpha. The counter going back, as from the end of the
+// download to the start of extraction, starts the rate over. Like
+// rateMeter's, the rate decays while n stands still and is dropped after
+// rateQuietAfter.
+func (r *layerRate) sample(now time.Time, n int64, alpha float64) {
+	if r.at.IsZero() || n < r.n {
+		*r = layerRate{at: now, n: n, movedAt: now}
+		return
+	}
+	dt := now.Sub(r.at)
+	if dt < minSampleInterval {
+		return
+	}
+	if n > r.n {
+		r.movedAt = now
+	}
+	inst := float64(n-r.n) / dt.Seconds()
+	switch {
+	case now.Sub(r.movedAt) >= rateQuietAfter:
+		r.bps = 0
+	case r.bps == 0:
+		r.bps = inst
+	default:
+		r.bps = alpha*inst + (1-alpha)*r.bps
+	}
+	r.at, r.n = now, n
+}
+
+// eta returns the time left for remaining bytes at the current rate, or 0
+// when either is unknown.
+func (r layerRate) eta(remaining int64) time.Duration {
+	if r.bps <= 0 || remaining <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / r.bps * float64(time.Second))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateMeterDecaysWhenStalled(t *testing.T) {
+	r := newRateMeter(defaultSmoothing)
+	start := time.Unix(0, 0)
+	const total = 100 << 20
+	var n int64
+	for i := 0; i <= 5; i++ {
+		r.sample(start.Add(time.Duration(i)*time.Second), n, total)
+		n += 1 << 20
+	}
+	n -= 1 << 20
+	moving, eta := r.rate, r.eta
+	if moving <= 0 || eta <= 0 {
+		t.Fatalf("rate, eta = %v, %v while moving, want both known", moving, eta)
+	}
+	// the stream goes quiet; only the rate tick samples
+	prev := moving
+	last := start.Add(5 * time.Second)
+	for i := 1; i < int(rateQuietAfter/time.Second); i++ {
+		r.sample(last.Add(time.Duration(i)*time.Second), n, total)
+		if r.rate >= prev {
+			t.Fatalf("after %ds stalled rate = %v, want it below %v", i, r.rate, prev)
+		}
+		prev = r.rate
+	}
+	r.sample(last.Add(rateQuietAfter), n, total)
+	if r.rate != 0 || r.eta != 0 {
+		t.Errorf("after %s stalled rate, eta = %v, %v, want both dropped", rateQuietAfter, r.rate, r.eta)
+	}
+	// and picks up again
+	r.sample(last.Add(rateQuietAfter+time.Second), n+1<<20, total)
+	if r.rate <= 0 {
+		t.Errorf("rate = %v after bytes moved again, want it known", r.rate)
+	}
+}
+
+func TestLayerRateDecaysWhenStalled(t *testing.T) {
+	var r layerRate
+	start := time.Unix(0, 0)
+	r.sample(start, 0, defaultSmoothing)
+	r.sample(start.Add(time.Second), 4<<20, defaultSmoothing)
+	if r.bps != 4<<20 {
+		t.Fatalf("bps = %v, want %v", r.bps, 4<<20)
+	}
+	r.sample(start.Add(2*time.Second), 4<<20, defaultSmoothing)
+	if r.bps >= 4<<20 {
+		t.Errorf("bps = %v after a quiet second, want it falling", r.bps)
+	}
+	r.sample(start.Add(time.Second+rateQuietAfter), 4<<20, defaultSmoothing)
+	if r.bps != 0 || r.eta(1<<20) != 0 {
+		t.Errorf("bps, eta = %v, %v after %s quiet, want both dropped", r.bps, r.eta(1<<20), rateQuietAfter)
+	}
+}
+
+// TestRateMeterBursty feeds bursts and lulls of bytes and checks that the
+// smoothed rate stays between the extremes and the ETA moves in bounded steps.
+func TestRateMeterBursty(t *testing.T) {
+	r := newRateMeter(defaultSmoothing)
+	start := time.Unix(0, 0)
+	const total = 1 << 30
+	bursts := []int64{10 << 20, 0, 0, 20 << 20, 1 << 20, 0, 15 << 20, 0, 5 << 20, 0}
+	var n int64
+	r.sample(start, n, total)
+	prevETA := time.Duration(0)
+	for i, b := range bursts {
+		n += b
+		r.sample(start.Add(time.Duration(i+1)*time.Second), n, total)
+		if r.rate <= 0 || r.rate > 20<<20 {
+			t.Fatalf("sample %d: rate = %v, want within (0, %d]", i, r.rate, 20<<20)
+		}
+		if prevETA > 0 {
+			step := max(prevETA/4, minETAStep)
+			if d := r.eta - prevETA; d > step || d < -step {
+				t.Errorf("sample %d: ETA moved %s from %s, more than %s", i, d, prevETA, step)
+			}
+		}
+		prevETA = r.eta
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// validateRef checks that image parses as a reference the daemon could pull,
+// so that a typo fails before the daemon is contacted.
+func validateRef(image string) error {
+	if strings.TrimSpace(image) == "" {
+		return errors.New("empty image reference")
+	}
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return fmt.Errorf("invalid image reference %q: %v", image, err)
+	}
+	return nil
+}
+
+// pulledRef returns the fully-qualified form of image pinned to dgst, e.g.
+// docker.io/library/node:20@sha256:….
+func pulledRef(image, dgst string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	d, err := digest.Parse(dgst)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := named.(reference.Digested); ok {
+		// already pinned; the stream echoes the same digest
+		return named.String(), nil
+	}
+	pinned, err := reference.WithDigest(reference.TagNameOnly(named), d)
+	if err != nil {
+		return "", err
+	}
+	return pinned.String(), nil
+}
+
+// requestedDigest returns the digest image is pinned to, e.g. for
+// node@sha256:…, or "" when it is referenced by tag.
+func requestedDigest(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	if d, ok := named.(reference.Digested); ok {
+		return d.Digest().String()
+	}
+	return ""
+}
+
+// resolvedTag returns a tag of the same repository among repoTags, as shown by
+// docker, for an image pulled by digest. It returns "" when image is not
+// pinned or no tag of its repository points at the image.
+func resolvedTag(image string, repoTags []string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	if _, ok := named.(reference.Digested); !ok {
+		return ""
+	}
+	for _, t := range repoTags {
+		tn, err := reference.ParseNormalizedNamed(t)
+		if err != nil || tn.Name() != named.Name() {
+			continue
+		}
+		if _, ok := tn.(reference.Tagged); ok {
+			return reference.FamiliarString(tn)
+		}
+	}
+	return ""
+}
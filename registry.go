@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"dockerpulltui/pull"
+
+	"github.com/distribution/reference"
+	registrytypes "github.com/docker/docker/api/types/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// manifestTypes are the manifest media types --no-daemon understands. Docker's
+// schema 2 formats share their JSON layout with the OCI ones.
+var manifestTypes = []string{
+	ocispec.MediaTypeImageIndex,
+	ocispec.MediaTypeImageManifest,
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// imageSize is the download cost of an image as described by its manifest.
+type imageSize struct {
+	layers int
+	size   int64
+}
+
+// registry talks to the distribution API of one repository, without a daemon.
+type registry struct {
+	client *http.Client
+	base   string // e.g. https://registry-1.docker.io/v2/library/node
+	repo   string
+	// user and password come from the docker CLI config, if logged in
+	user, password string
+	// token is the Authorization header value once a challenge was answered
+	token string
+}
+
+func newRegistry(named reference.Named) *registry {
+	domain := reference.Domain(named)
+	host := domain
+	if domain == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	r := &registry{
+		client: http.DefaultClient,
+		base:   "https://" + host + "/v2/" + reference.Path(named),
+		repo:   reference.Path(named),
+	}
+	auth := configAuth(domain)
+	r.user, r.password = auth.Username, auth.Password
+	return r
+}
+
+// queryImageSize sums the compressed layer sizes of image's manifest for the
+// platform this binary runs on. Sizes come from the manifest's descriptors, so
+// no blob is fetched.
+func queryImageSize(ctx context.Context, image string) (imageSize, error) {
+	m, err := fetchManifest(ctx, image, "linux", runtime.GOARCH)
+	if err != nil {
+		return imageSize{}, err
+	}
+	res := imageSize{layers: len(m.Layers)}
+	for _, l := range m.Layers {
+		res.size += l.Size
+	}
+	return res, nil
+}
+
+// fetchManifest returns image's manifest for the osName/arch platform, following
+// an index to the matching entry.
+func fetchManifest(ctx context.Context, image, osName, arch string) (ocispec.Manifest, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ocispec.Manifest{}, err
+	}
+	named = reference.TagNameOnly(named)
+	var ref string
+	if d, ok := named.(reference.Digested); ok {
+		ref = d.Digest().String()
+	} else {
+		ref = named.(reference.Tagged).Tag()
+	}
+	r := newRegistry(named)
+	var m struct {
+		ocispec.Manifest
+		Manifests []ocispec.Descriptor `json:"manifests"`
+	}
+	if err := r.getManifest(ctx, ref, &m); err != nil {
+		return ocispec.Manifest{}, err
+	}
+	if m.Manifests != nil {
+		// an index; follow the entry for the platform
+		d, ok := matchPlatform(m.Manifests, osName, arch)
+		if !ok {
+			return ocispec.Manifest{}, fmt.Errorf("no manifest for %s/%s", osName, arch)
+		}
+		m.Manifests = nil
+		if err := r.getManifest(ctx, d.Digest.String(), &m); err != nil {
+			return ocispec.Manifest{}, err
+		}
+	}
+	return m.Manifest, nil
+}
+
+// matchPlatform picks the manifest for osName/arch, as a daemon on that platform
+// would.
+func matchPlatform(ds []ocispec.Descriptor, osName, arch string) (ocispec.Descriptor, bool) {
+	for _, d := range ds {
+		if p := d.Platform; p != nil && p.OS == osName && p.Architecture == arch {
+			return d, true
+		}
+	}
+	return ocispec.Descriptor{}, false
+}
+
+func (r *registry) getManifest(ctx context.Context, ref string, v any) error {
+	resp, err := r.do(ctx, r.base+"/manifests/"+ref)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// do GETs u, answering a token or basic auth challenge once.
+func (r *registry) do(ctx context.Context, u string) (*http.Response, error) {
+	resp, err := r.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := r.authorize(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = r.get(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, registryError(resp)
+	}
+	return resp, nil
+}
+
+func (r *registry) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if r.token != "" {
+		req.Header.Set("Authorization", r.token)
+	}
+	return r.client.Do(req)
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authorize sets r.token from a WWW-Authenticate challenge.
+func (r *registry) authorize(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if r.user == "" {
+			return errors.New("unauthorized: authentication required")
+		}
+		r.token = "Basic " + base64.StdEncoding.EncodeToString([]byte(r.user+":"+r.password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	p := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		p[m[1]] = m[2]
+	}
+	q := url.Values{"scope": {"repository:" + r.repo + ":pull"}}
+	if p["service"] != "" {
+		q.Set("service", p["service"])
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if r.user != "" {
+		req.SetBasicAuth(r.user, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return registryError(resp)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return err
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	r.token = "Bearer " + tok.Token
+	return nil
+}
+
+// registryError turns an error response into an error pull.Classify knows,
+// using the distribution error body when there is one.
+func registryError(resp *http.Response) error {
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(b, &body) == nil && len(body.Errors) > 0 {
+		e := body.Errors[0]
+		return fmt.Errorf("%s: %s", strings.ToLower(e.Code), e.Message)
+	}
+	return fmt.Errorf("registry returned %s", resp.Status)
+}
+
+// configAuth returns the credentials `docker login` stored for domain in the
+// docker CLI config: through the credential helper the config names for the
+// registry, or its credsStore, and otherwise inline. The zero AuthConfig
+// means there are none.
+func configAuth(domain string) registrytypes.AuthConfig {
+	b, err := os.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if err != nil {
+		return registrytypes.AuthConfig{}
+	}
+	var cfg struct {
+		Auths map[string]struct {
+			Auth          string `json:"auth"`
+			IdentityToken string `json:"identitytoken"`
+		} `json:"auths"`
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}
+	if json.Unmarshal(b, &cfg) != nil {
+		return registrytypes.AuthConfig{}
+	}
+	key := domain
+	if domain == "docker.io" {
+		key = "https://index.docker.io/v1/"
+	}
+	helper := cfg.CredsStore
+	if h, ok := cfg.CredHelpers[key]; ok {
+		helper = h
+	}
+	if helper != "" {
+		if auth, err := helperAuth(helper, key); err == nil {
+			return auth
+		}
+	}
+	inline := cfg.Auths[key]
+	auth := registrytypes.AuthConfig{ServerAddress: key, IdentityToken: inline.IdentityToken}
+	if dec, err := base64.StdEncoding.DecodeString(inline.Auth); err == nil {
+		auth.Username, auth.Password, _ = strings.Cut(string(dec), ":")
+	}
+	if auth.Username == "" && auth.IdentityToken == "" {
+		return registrytypes.AuthConfig{}
+	}
+	return auth
+}
+
+// helperAuth asks the docker-credential-<helper> program for the credentials
+// of server, as the docker CLI does.
+func helperAuth(helper, server string) (registrytypes.AuthConfig, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	out, err := cmd.Output()
+	if err != nil {
+		return registrytypes.AuthConfig{}, err
+	}
+	var cred struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return registrytypes.AuthConfig{}, err
+	}
+	auth := registrytypes.AuthConfig{ServerAddress: server}
+	if cred.Username == "<token>" {
+		// what helpers return for an identity token
+		auth.IdentityToken = cred.Secret
+	} else {
+		auth.Username, auth.Password = cred.Username, cred.Secret
+	}
+	return auth, nil
+}
+
+// printImageSizes reports the layer count and download size of each image for
+// --no-daemon, and any failure on stderr in the same form as a failed pull. It
+// returns the first failure.
+func printImageSizes(ctx context.Context, images []string, opts options) error {
+	var first error
+	for _, image := range images {
+		res, err := queryImageSize(ctx, image)
+		if err != nil {
+			errorf("%s: %v", image, err)
+			if hint := pull.Classify(err).Hint(); hint != "" && !quietErrors {
+				fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+			}
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		fmt.Printf("%s: %d layers, %s\n", image, res.layers, opts.bytes(res.size))
+	}
+	return first
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dockerpulltui/pull"
+)
+
+// TestPrintImageSizesFailure serves a registry that knows one repository,
+// refuses another and lacks a third, and checks that --no-daemon reports the
+// first failure with the exit code of its kind.
+func TestPrintImageSizesFailure(t *testing.T) {
+	withConfig(t, `{}`)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/"):
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write([]byte(`{"schemaVersion":2,"layers":[{"size":1024},{"size":2048}]}`))
+		case strings.HasPrefix(r.URL.Path, "/v2/team/private/"):
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`))
+		}
+	}))
+	defer srv.Close()
+	saved := http.DefaultClient.Transport
+	http.DefaultClient.Transport = srv.Client().Transport
+	defer func() { http.DefaultClient.Transport = saved }()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	tests := []struct {
+		name   string
+		images []string
+		want   int
+	}{
+		{"found", []string{host + "/team/app:1"}, exitOK},
+		{"not found", []string{host + "/team/app:1", host + "/team/gone:1"}, exitNotFound},
+		{"first failure wins", []string{host + "/team/private:1", host + "/team/gone:1"}, exitAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := printImageSizes(context.Background(), tt.images, options{})
+			got := exitOK
+			if err != nil {
+				got = exitCodeFor(pull.Classify(err))
+			}
+			if got != tt.want {
+				t.Errorf("exit code %d (err %v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// registryStats totals the pulls of one registry for --group-by-registry.
+type registryStats struct {
+	registry    string
+	images      int
+	failed      int
+	transferred int64
+	// busy is the summed duration of the registry's pulls
+	busy time.Duration
+}
+
+// registryOf returns the registry host of image, e.g. docker.io for node:20.
+func registryOf(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "(invalid)"
+	}
+	return reference.Domain(named)
+}
+
+// groupByRegistry totals the pulls that started, per registry, ordered by
+// bytes transferred and then by name. With mergeLayers, layers shared between
+// pulls count once.
+func groupByRegistry(pulls []*imagePull, mergeLayers bool) []registryStats {
+	var credit map[*imagePull]int64
+	if mergeLayers {
+		credit = creditLayers(pulls)
+	}
+	byName := map[string]*registryStats{}
+	for _, p := range pulls {
+		if !p.started {
+			continue
+		}
+		name := registryOf(p.image)
+		st := byName[name]
+		if st == nil {
+			st = &registryStats{registry: name}
+			byName[name] = st
+		}
+		st.images++
+		if p.err != nil {
+			st.failed++
+		}
+		if mergeLayers {
+			st.transferred += credit[p]
+		} else {
+			st.transferred += p.transferred
+		}
+		if !p.endedAt.IsZero() {
+			st.busy += p.endedAt.Sub(p.startedAt)
+		}
+	}
+	stats := make([]registryStats, 0, len(byName))
+	for _, st := range byName {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].transferred != stats[j].transferred {
+			return stats[i].transferred > stats[j].transferred
+		}
+		return stats[i].registry < stats[j].registry
+	})
+	return stats
+}
+
+// printRegistrySummary writes a table of images, failures, bytes transferred
+// and average rate per registry. The rate is averaged over the summed
+// duration of the registry's pulls.
+func printRegistrySummary(w io.Writer, pulls []*imagePull, opts options) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGISTRY\tIMAGES\tFAILED\tPULLED\tRATE")
+	for _, st := range groupByRegistry(pulls, opts.mergeLayers) {
+		rate := "-"
+		if st.busy > 0 && st.transferred > 0 {
+			rate = opts.bytes(int64(float64(st.transferred)/st.busy.Seconds())) + "/s"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", st.registry, st.images, st.failed, opts.bytes(st.transferred), rate)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestMergeLayers pulls two images sharing a base layer and checks that
+// --merge-layers-by-digest counts that layer once in the overall progress
+// and in the pulled totals.
+func TestMergeLayers(t *testing.T) {
+	const mb = 1 << 20
+	events := []progressEvent{
+		// a layer of each image's own, and the shared base layer
+		{idx: 0, Event: pull.Event{ID: "app", Status: "Downloading", Current: 0, Total: 4 * mb, HasCounts: true}},
+		{idx: 1, Event: pull.Event{ID: "web", Status: "Downloading", Current: 0, Total: 4 * mb, HasCounts: true}},
+		{idx: 0, Event: pull.Event{ID: "base", Status: "Downloading", Current: 0, Total: 4 * mb, HasCounts: true}},
+		{idx: 1, Event: pull.Event{ID: "base", Status: "Downloading", Current: 0, Total: 4 * mb, HasCounts: true}},
+		// the base layer half downloaded, as both streams report it
+		{idx: 0, Event: pull.Event{ID: "base", Status: "Downloading", Current: 2 * mb, Total: 4 * mb, HasCounts: true}},
+		{idx: 1, Event: pull.Event{ID: "base", Status: "Downloading", Current: 2 * mb, Total: 4 * mb, HasCounts: true}},
+	}
+	tests := []struct {
+		merge    bool
+		frac     float64
+		credited int64
+	}{
+		// each pull is at 2 of 8MB
+		{merge: false, frac: 0.25, credited: 4 * mb},
+		// 2 of 12 distinct MB, and the base layer's bytes credited once
+		{merge: true, frac: 2.0 / 12, credited: 2 * mb},
+	}
+	for _, tt := range tests {
+		opts := options{mergeLayers: tt.merge}
+		var m tea.Model = startedModel([]string{"node:20", "nginx:1"}, opts)
+		for _, ev := range events {
+			m, _ = m.Update(ev)
+		}
+		mm := m.(model)
+		if _, _, frac := mm.overall(); math.Abs(frac-tt.frac) > 1e-9 {
+			t.Errorf("merge=%v: overall fraction = %v, want %v", tt.merge, frac, tt.frac)
+		}
+		var credited int64
+		for _, st := range groupByRegistry(mm.pulls, tt.merge) {
+			credited += st.transferred
+		}
+		if credited != tt.credited {
+			t.Errorf("merge=%v: registry totals = %d, want %d", tt.merge, credited, tt.credited)
+		}
+		path := filepath.Join(t.TempDir(), "summary.json")
+		summarySink{path: path, mergeLayers: tt.merge}.end(mm.pulls)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Totals summaryTotals `json:"totals"`
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Totals.Pulled != tt.credited {
+			t.Errorf("merge=%v: summary pulledBytes = %d, want %d", tt.merge, doc.Totals.Pulled, tt.credited)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"dockerpulltui/pull"
+)
+
+// replayInterval spaces replayed messages at 1x when the fixture carries no
+// timestamps.
+const replayInterval = 100 * time.Millisecond
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// replayInput returns the pull stream of a --from-stdin capture, which may be
+// gzip-compressed: captures of real pulls are large, and a plain stream never
+// starts with gzip's magic bytes.
+func replayInput(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading compressed capture: %w", err)
+	}
+	return zr, nil
+}
+
+// parseReplaySpeed parses a --replay-speed value such as "2x", "0.5x" or
+// "realtime" (same as "1x"). The empty string means no pacing and yields 0.
+func parseReplaySpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "":
+		return 0, nil
+	case "realtime":
+		return 1, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("invalid replay speed %q (want e.g. 1x, 2x or realtime)", s)
+	}
+	return f, nil
+}
+
+// pacer delays replayed stream messages to simulate a real pull. Messages are
+// spaced by the gaps between their time/timeNano fields when present, and
+// evenly otherwise, scaled by speed.
+type pacer struct {
+	speed float64
+	// last is the timestamp of the previous message in nanoseconds, or 0
+	last int64
+}
+
+func newPacer(speed float64) *pacer {
+	if speed <= 0 {
+		return nil
+	}
+	return &pacer{speed: speed}
+}
+
+// wait blocks until message e is due, or ctx is done.
+func (p *pacer) wait(ctx context.Context, e map[string]any) error {
+	delay := replayInterval
+	if ts := messageTime(e); ts > 0 {
+		if p.last > 0 && ts >= p.last {
+			delay = time.Duration(ts - p.last)
+		} else {
+			delay = 0
+		}
+		p.last = ts
+	}
+	delay = time.Duration(float64(delay) / p.speed)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// filter returns a pull.Filter that waits for each message to be due. Once
+// ctx is done it drops messages so the decoder can stop.
+func (p *pacer) filter(ctx context.Context) pull.Filter {
+	return func(e map[string]any) bool {
+		return p.wait(ctx, e) == nil
+	}
+}
+
+// messageTime returns the timestamp of a stream message in nanoseconds, or 0
+// if it has none.
+func messageTime(e map[string]any) int64 {
+	if n, ok := e["timeNano"].(float64); ok && n > 0 {
+		return int64(n)
+	}
+	if s, ok := e["time"].(float64); ok && s > 0 {
+		return int64(s) * int64(time.Second)
+	}
+	return 0
+}
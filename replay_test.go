@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// replayFixture feeds the captured pull stream testdata/name through the
+// decoder and the model, as --from-stdin would, and returns the pull once
+// its stream has ended.
+func replayFixture(t *testing.T, name string, opts options) *imagePull {
+	t.Helper()
+	m := endPull(replayEvents(t, name, opts), 0)
+	return m.(model).pulls[0]
+}
+
+// replayEvents is replayFixture short of the end of the stream, and returns
+// the model.
+func replayEvents(t *testing.T, name string, opts options) tea.Model {
+	t.Helper()
+	return replayEach(t, name, name, opts, nil)
+}
+
+// replayEach is replayEvents pulling image, and calling each, if set, with
+// the model after every event.
+func replayEach(t *testing.T, name, image string, opts options, each func(tea.Model)) tea.Model {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var m tea.Model = startedModel([]string{image}, opts)
+	err = pull.Decode(context.Background(), f, nil, func(ev pull.Event) {
+		m, _ = m.Update(progressEvent{idx: 0, Event: ev})
+		if each != nil {
+			each(m)
+		}
+	})
+	if err != nil {
+		t.Fatalf("decode %s: %v", name, err)
+	}
+	return m
+}
+
+// startedModel returns a model whose pulls of images have all started
+// without running a goroutine, for driving Update by hand.
+func startedModel(images []string, opts options) model {
+	m := initialModel(images, opts)
+	for _, p := range m.pulls {
+		p.started = true
+		p.startedAt = now()
+	}
+	return m
+}
+
+// endPull delivers the end of pull idx's stream, and its last frame when it
+// shows one.
+func endPull(m tea.Model, idx int) tea.Model {
+	m, _ = m.Update(pullDone{idx: idx})
+	if m.(model).pulls[idx].completing {
+		m, _ = m.Update(pullCompleted{idx})
+	}
+	return m
+}
+
+// TestReplayEmptyProgressDetail replays a stream whose statuses without byte
+// counts carry an empty progressDetail or only {"hidecounts":true}, and
+// checks that none of them resets a layer's counted bytes.
+func TestReplayEmptyProgressDetail(t *testing.T) {
+	const mb = 1 << 20
+	var prev layerTotals
+	m := replayEach(t, "empty-progress-detail.json", "redis:7", options{}, func(m tea.Model) {
+		sums := m.(model).pulls[0].layers.totals()
+		if sums.current < prev.current || sums.total < prev.total {
+			t.Fatalf("bytes went back from %d/%d to %d/%d", prev.current, prev.total, sums.current, sums.total)
+		}
+		prev = sums
+	})
+	p := endPull(m, 0).(model).pulls[0]
+	if sums := p.layers.totals(); sums.current != 6*mb || sums.total != 6*mb {
+		t.Errorf("layers at %d/%d, want %d/%d", sums.current, sums.total, 6*mb, 6*mb)
+	}
+	if p.transferred != 6*mb || p.reused != 0 {
+		t.Errorf("transferred, reused = %d, %d, want %d, 0", p.transferred, p.reused, 6*mb)
+	}
+	if !p.done || p.err != nil {
+		t.Errorf("done, err = %v, %v, want success", p.done, p.err)
+	}
+}
+
+// TestReplayPullByDigest replays a pull of a digest ref, whose stream echoes
+// the digest instead of a tag, and checks the digest against the requested
+// one and that the pull completes.
+func TestReplayPullByDigest(t *testing.T) {
+	const digest = "sha256:4a1b7c3e9f0d2b6a8c5e7f1a3b9d0c2e4f6a8b1c3d5e7f9a0b2c4d6e8f1a3b5c"
+	tests := []struct {
+		name  string
+		image string
+		// mismatch is whether the stream's digest differs from the image's
+		mismatch bool
+	}{
+		{"pinned", "node@" + digest, false},
+		{"pinned with a tag", "node:20@" + digest, false},
+		{"other digest", "node@sha256:" + strings.Repeat("0", 64), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := replayEach(t, "pull-by-digest.json", tt.image, options{}, nil)
+			m, _ = m.Update(pullDone{idx: 0, imageResult: imageResult{imageID: "sha256:" + strings.Repeat("e", 64), repoTags: []string{"node:20", "redis:7"}}})
+			if m.(model).pulls[0].completing {
+				m, _ = m.Update(pullCompleted{0})
+			}
+			p := m.(model).pulls[0]
+			if p.digest != digest {
+				t.Errorf("digest = %q, want %q", p.digest, digest)
+			}
+			if tt.mismatch {
+				if p.err == nil || !strings.Contains(p.err.Error(), "does not match") {
+					t.Errorf("err = %v, want a digest mismatch", p.err)
+				}
+				return
+			}
+			if !p.done || p.err != nil {
+				t.Fatalf("done, err = %v, %v, want success", p.done, p.err)
+			}
+			if s := p.summary(options{}); !strings.Contains(s, "known as node:20") {
+				t.Errorf("summary() = %q, want the resolved tag", s)
+			}
+		})
+	}
+}
+
+// TestReplaySharedLayer replays a pull in which another process fetches a
+// layer: it completes without this stream downloading any of its bytes. The
+// layer must count as already present, never as bytes this pull moved, so
+// neither the rate nor the totals jump when it completes.
+func TestReplaySharedLayer(t *testing.T) {
+	const mb = 1 << 20
+	m := replayEach(t, "shared-layer.json", "python:3.12", options{eta: true, smoothing: defaultSmoothing}, func(m tea.Model) {
+		// the rate meter samples these bytes
+		if got := m.(model).pulls[0].layers.totals().transferred; got > 5*mb {
+			t.Fatalf("transferred %d mid-pull, more than the %d downloaded", got, 5*mb)
+		}
+	})
+	p := endPull(m, 0).(model).pulls[0]
+	shared, ok := p.layers.get("9e7d6c5b4a31")
+	if !ok {
+		t.Fatal("shared layer not seen")
+	}
+	if shared.baseline != shared.total || shared.total != 30*mb {
+		t.Errorf("shared layer baseline, total = %d, %d, want both %d", shared.baseline, shared.total, 30*mb)
+	}
+	if p.transferred != 5*mb || p.reused != 30*mb {
+		t.Errorf("transferred, reused = %d, %d, want %d, %d", p.transferred, p.reused, 5*mb, 30*mb)
+	}
+	if !p.done || p.err != nil {
+		t.Errorf("done, err = %v, %v, want success", p.done, p.err)
+	}
+}
+
+// TestReplayExtractProgress replays a pull through its checksum and extract
+// phases with --checksum-progress, and checks the phase each frame shows
+// once every layer has downloaded.
+func TestReplayExtractProgress(t *testing.T) {
+	phase := regexp.MustCompile(`verifying|extracting \d+%`)
+	var shown []string
+	m := replayEach(t, "extract-progress.json", "postgres:16", options{checksumProgress: true}, func(m tea.Model) {
+		ph := phase.FindString(m.View())
+		if ph != "" && (len(shown) == 0 || shown[len(shown)-1] != ph) {
+			shown = append(shown, ph)
+		}
+	})
+	want := []string{"verifying", "extracting 33%", "extracting 66%", "extracting 83%", "extracting 100%"}
+	if !slices.Equal(shown, want) {
+		t.Errorf("phases shown = %q, want %q", shown, want)
+	}
+	if p := endPull(m, 0).(model).pulls[0]; !p.done || p.err != nil {
+		t.Errorf("done, err = %v, %v, want success", p.done, p.err)
+	}
+}
+
+// TestReplayResumedDownload replays a pull whose layer resumes mid-download
+// after a retry, a second apart per event, and checks that the bytes it
+// already had neither count as transferred nor inflate the rate: no second
+// moves more than 1MB.
+func TestReplayResumedDownload(t *testing.T) {
+	const mb = 1 << 20
+	clock := time.Unix(0, 0)
+	saved := now
+	now = func() time.Time { return clock }
+	defer func() { now = saved }()
+
+	m := replayEach(t, "resumed-download.json", "golang:1.23", options{eta: true, smoothing: defaultSmoothing}, func(m tea.Model) {
+		if rate := m.(model).pulls[0].meter.rate; rate > mb {
+			t.Fatalf("rate = %.0f B/s, more than the %d moved per second", rate, mb)
+		}
+		clock = clock.Add(time.Second)
+	})
+	p := endPull(m, 0).(model).pulls[0]
+	resumed, _ := p.layers.get("e4fd1a8c3b27")
+	if resumed.baseline != 3*mb {
+		t.Errorf("resumed layer baseline = %d, want %d", resumed.baseline, 3*mb)
+	}
+	if p.transferred != 7*mb || p.reused != 3*mb {
+		t.Errorf("transferred, reused = %d, %d, want %d, %d", p.transferred, p.reused, 7*mb, 3*mb)
+	}
+}
+
+// TestReplayUnknownSize replays a layer downloading with no byte total, and
+// checks that its pull still shows the transfer rate, without an ETA.
+func TestReplayUnknownSize(t *testing.T) {
+	clock := time.Unix(0, 0)
+	saved := now
+	now = func() time.Time { return clock }
+	defer func() { now = saved }()
+
+	opts := options{eta: true, smoothing: defaultSmoothing}
+	m := replayEach(t, "unknown-size.json", "alpine:3.20", opts, func(tea.Model) {
+		clock = clock.Add(time.Second)
+	})
+	line := m.(model).pulls[0].View(opts)
+	if !strings.Contains(line, "/s") {
+		t.Errorf("view %q has no rate", line)
+	}
+	if strings.Contains(line, "ETA") {
+		t.Errorf("view %q has an ETA with no byte total known", line)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats for --report-format.
+const (
+	reportText     = "text"
+	reportMarkdown = "md"
+)
+
+// reportRow is one image of the final report.
+type reportRow struct {
+	image, result, id, pulled, size, duration string
+}
+
+var reportHeader = reportRow{"IMAGE", "RESULT", "ID", "PULLED", "SIZE", "DURATION"}
+
+func (r reportRow) cells() []string {
+	return []string{r.image, r.result, r.id, r.pulled, r.size, r.duration}
+}
+
+// reportResult names how p ended, in a word or two.
+func (p *imagePull) reportResult() string {
+	switch {
+	case p.cancelReason != notCancelled:
+		return "cancelled"
+	case !p.started:
+		return "skipped"
+	case p.err != nil:
+		return "failed"
+	case p.present:
+		return "present"
+	case p.hideBar || !p.sawDownload:
+		return "up to date"
+	}
+	return "pulled"
+}
+
+func reportRows(pulls []*imagePull, opts options) []reportRow {
+	rows := make([]reportRow, len(pulls))
+	for i, p := range pulls {
+		r := reportRow{image: p.image, result: p.reportResult(), id: "-", pulled: "-", size: "-", duration: "-"}
+		if p.imageID != "" {
+			r.id = shortID(p.imageID)
+		}
+		if p.transferred > 0 {
+			r.pulled = opts.bytes(p.transferred)
+		}
+		if p.size > 0 {
+			r.size = opts.bytes(p.size)
+		}
+		if p.started && !p.endedAt.IsZero() {
+			r.duration = humanDuration(p.endedAt.Sub(p.startedAt))
+		}
+		rows[i] = r
+	}
+	return rows
+}
+
+// printReport writes a table of every image's result, bytes pulled, image
+// size and duration, as aligned text or as a Markdown table for CI step
+// summaries and PR comments.
+func printReport(w io.Writer, pulls []*imagePull, format string, opts options) {
+	rows := reportRows(pulls, opts)
+	if format == reportMarkdown {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(reportHeader.cells(), " | "))
+		fmt.Fprintln(w, "|---|---|---|--:|--:|--:|")
+		for _, r := range rows {
+			cells := r.cells()
+			// an image ref may not break the table
+			cells[0] = "`" + strings.ReplaceAll(cells[0], "|", `\|`) + "`"
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range append([]reportRow{reportHeader}, rows...) {
+		fmt.Fprintln(tw, strings.Join(r.cells(), "\t"))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+const (
+	// simTick is how often the simulation reports progress.
+	simTick = 100 * time.Millisecond
+	// simConcurrent is how many layers download at once, like the daemon's
+	// default max-concurrent-downloads.
+	simConcurrent = 3
+	// simExtractFactor is how much faster layers extract than download.
+	simExtractFactor = 3
+)
+
+// simulation describes a synthetic pull for --simulate: layers of random
+// sizes adding up to size, downloaded at speed bytes per second.
+type simulation struct {
+	layers int
+	size   int64
+	speed  int64
+}
+
+// parseSimulation parses the --simulate-* flags; sizes take units such as
+// 200MB or 1.5GB.
+func parseSimulation(layers int, size, speed string) (*simulation, error) {
+	if layers < 1 {
+		return nil, fmt.Errorf("--simulate-layers must be positive")
+	}
+	s := &simulation{layers: layers}
+	var err error
+	if s.size, err = units.RAMInBytes(size); err != nil || s.size < int64(layers) {
+		return nil, fmt.Errorf("invalid --simulate-size %q", size)
+	}
+	if s.speed, err = units.RAMInBytes(speed); err != nil || s.speed <= 0 {
+		return nil, fmt.Errorf("invalid --simulate-speed %q", speed)
+	}
+	return s, nil
+}
+
+// simLayer is one layer of a running simulation.
+type simLayer struct {
+	id        string
+	total     int64
+	current   int64
+	extracted int64
+}
+
+// stream writes the simulated pull of image to w as a daemon pull stream,
+// paced in real time, and closes w. It stops early with ctx's error.
+func (s *simulation) stream(ctx context.Context, image string, w *io.PipeWriter) {
+	enc := json.NewEncoder(w)
+	send := func(msg map[string]any) {
+		_ = enc.Encode(msg)
+	}
+	layers := s.split()
+	send(map[string]any{"status": "Pulling from " + image, "id": "latest"})
+	for _, l := range layers {
+		send(map[string]any{"status": "Pulling fs layer", "progressDetail": map[string]any{}, "id": l.id})
+	}
+	for _, l := range layers[min(simConcurrent, len(layers)):] {
+		send(map[string]any{"status": "Waiting", "progressDetail": map[string]any{}, "id": l.id})
+	}
+	progress := func(status string, l *simLayer, current int64) {
+		send(map[string]any{"status": status, "progressDetail": map[string]any{"current": current, "total": l.total}, "id": l.id})
+	}
+	tick := time.NewTicker(simTick)
+	defer tick.Stop()
+	next := 0 // first layer not yet extracted, which must go in order
+	for next < len(layers) {
+		select {
+		case <-ctx.Done():
+			w.CloseWithError(ctx.Err())
+			return
+		case <-tick.C:
+		}
+		budget := s.speed * int64(simTick) / int64(time.Second)
+		var active []*simLayer
+		for _, l := range layers {
+			if l.current < l.total && len(active) < simConcurrent {
+				active = append(active, l)
+			}
+		}
+		for _, l := range active {
+			// jitter each layer's share so the bars do not move in lockstep
+			share := budget / int64(len(active)) * int64(80+rand.IntN(41)) / 100
+			l.current = min(l.current+max(share, 1), l.total)
+			progress("Downloading", l, l.current)
+			if l.current == l.total {
+				send(map[string]any{"status": "Verifying Checksum", "progressDetail": map[string]any{}, "id": l.id})
+				send(map[string]any{"status": "Download complete", "progressDetail": map[string]any{}, "id": l.id})
+			}
+		}
+		if l := layers[next]; l.current == l.total {
+			// as with downloads, a speed below a byte per tick still moves
+			l.extracted = min(l.extracted+max(budget*simExtractFactor, 1), l.total)
+			progress("Extracting", l, l.extracted)
+			if l.extracted == l.total {
+				send(map[string]any{"status": "Pull complete", "progressDetail": map[string]any{}, "id": l.id})
+				next++
+			}
+		}
+	}
+	send(map[string]any{"status": "Digest: sha256:" + randomHex(64)})
+	send(map[string]any{"status": "Status: Downloaded newer image for " + image})
+	w.Close()
+}
+
+// split returns the simulation's layers with random sizes adding up to size.
+func (s *simulation) split() []*simLayer {
+	weights := make([]float64, s.layers)
+	var sum float64
+	for i := range weights {
+		// a few big layers and many small ones, as in real images
+		weights[i] = rand.ExpFloat64()
+		sum += weights[i]
+	}
+	layers := make([]*simLayer, s.layers)
+	left := s.size
+	for i, wt := range weights {
+		total := max(int64(float64(s.size)*wt/sum), 1)
+		if i == len(weights)-1 {
+			total = max(left, 1)
+		}
+		left -= total
+		layers[i] = &simLayer{id: randomHex(12), total: total}
+	}
+	return layers
+}
+
+func randomHex(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[rand.IntN(len(digits))]
+	}
+	return string(b)
+}
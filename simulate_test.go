@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSimulateSlowSpeed streams a simulation slower than a byte per tick,
+// whose budget rounds to zero, and checks that it still ends.
+func TestSimulateSlowSpeed(t *testing.T) {
+	s, err := parseSimulation(1, "3", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, w := io.Pipe()
+	go s.stream(ctx, "slow:1", w)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("stream ended with %v", err)
+	}
+	if !strings.Contains(string(b), "Downloaded newer image for slow:1") {
+		t.Errorf("stream did not complete: %s", b)
+	}
+}
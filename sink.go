@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// sink receives a run's progress besides the rendered bars. Any number of
+// them can follow one run, e.g. --rawjson-fd, --json and --summary together.
+type sink interface {
+	// pullChanged is called when p starts and when it ends
+	pullChanged(p *imagePull)
+	// layerChanged is called after an event updated layer id of p
+	layerChanged(p *imagePull, id string)
+	// end is called once every pull of the run has ended
+	end(pulls []*imagePull)
+}
+
+// sinks fans each call out to every sink in turn. The nil sinks does nothing.
+type sinks []sink
+
+func (s sinks) pullChanged(p *imagePull) {
+	for _, k := range s {
+		k.pullChanged(p)
+	}
+}
+
+func (s sinks) layerChanged(p *imagePull, id string) {
+	for _, k := range s {
+		k.layerChanged(p, id)
+	}
+}
+
+func (s sinks) end(pulls []*imagePull) {
+	for _, k := range s {
+		k.end(pulls)
+	}
+}
+
+// jsonEvent is one line of --json and --format json: the state of an image
+// after it started, ended, or one of its layers changed.
+type jsonEvent struct {
+	Time       time.Time   `json:"timestamp"`
+	RunID      string      `json:"runID"`
+	Image      string      `json:"image"`
+	Status     string      `json:"status"`
+	OverallPct float64     `json:"overallPct"`
+	Layers     []jsonLayer `json:"layers,omitempty"`
+	// Message is the last image-level status of the stream, e.g. its digest
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Done and Cancelled are set only on the last event of an image, which
+	// tells consumers its stream ended
+	Done      *bool `json:"done,omitempty"`
+	Cancelled *bool `json:"cancelled,omitempty"`
+	// Reason is what cancelled the image, e.g. signal or fail-fast
+	Reason string `json:"reason,omitempty"`
+}
+
+type jsonLayer struct {
+	ID      string `json:"id"`
+	Current int64  `json:"current"`
+	Total   int64  `json:"total"`
+	Status  string `json:"status"`
+}
+
+// jsonSink writes jsonEvents as newline-delimited JSON.
+type jsonSink struct {
+	enc *json.Encoder
+	// runID is stamped on every event
+	runID string
+}
+
+func newJSONSink(w io.Writer, runID string) jsonSink {
+	return jsonSink{enc: json.NewEncoder(w), runID: runID}
+}
+
+func (s jsonSink) pullChanged(p *imagePull) { s.write(p) }
+
+func (s jsonSink) layerChanged(p *imagePull, _ string) { s.write(p) }
+
+func (jsonSink) end([]*imagePull) {}
+
+func (s jsonSink) write(p *imagePull) {
+	ev := jsonEvent{
+		Time:       now(),
+		RunID:      s.runID,
+		Image:      p.image,
+		Status:     "pulling",
+		OverallPct: 100 * p.pl.Percent,
+		Message:    p.message,
+	}
+	if !p.endedAt.IsZero() {
+		ev.Status = p.reportResult()
+		cancelled := p.cancelReason != notCancelled
+		done := p.done && !cancelled
+		ev.Done, ev.Cancelled = &done, &cancelled
+		ev.Reason = p.cancelReason.String()
+	}
+	if p.err != nil {
+		ev.Error = p.err.Error()
+	}
+	for _, l := range p.layers.snapshot() {
+		ev.Layers = append(ev.Layers, jsonLayer{ID: l.id, Current: l.current, Total: l.total, Status: l.status})
+	}
+	_ = s.enc.Encode(ev)
+}
+
+// summaryImage is one image of the --summary report.
+type summaryImage struct {
+	Image    string  `json:"image"`
+	Result   string  `json:"result"`
+	ID       string  `json:"id,omitempty"`
+	Pulled   int64   `json:"pulledBytes"`
+	Reused   int64   `json:"reusedBytes"`
+	Size     int64   `json:"sizeBytes,omitempty"`
+	Duration float64 `json:"durationSeconds,omitempty"`
+	// PeakDownloads and AvgDownloads are how many layers downloaded at once
+	PeakDownloads int     `json:"peakConcurrentDownloads,omitempty"`
+	AvgDownloads  float64 `json:"avgConcurrentDownloads,omitempty"`
+	Error         string  `json:"error,omitempty"`
+	// Reason is what cancelled the image, e.g. signal or fail-fast
+	Reason string `json:"reason,omitempty"`
+}
+
+// summaryTotals sums the --summary report over every image.
+type summaryTotals struct {
+	Pulled int64 `json:"pulledBytes"`
+}
+
+// summarySink writes the results of the run as one JSON document to path
+// once the run ends, replacing the file of a previous --watch run. With
+// mergeLayers, layers shared between images count once in the totals.
+type summarySink struct {
+	path        string
+	runID       string
+	mergeLayers bool
+}
+
+func (summarySink) pullChanged(*imagePull) {}
+
+func (summarySink) layerChanged(*imagePull, string) {}
+
+func (s summarySink) end(pulls []*imagePull) {
+	var doc struct {
+		RunID  string         `json:"runID"`
+		Images []summaryImage `json:"images"`
+		Totals summaryTotals  `json:"totals"`
+	}
+	doc.RunID = s.runID
+	var credit map[*imagePull]int64
+	if s.mergeLayers {
+		credit = creditLayers(pulls)
+	}
+	for _, p := range pulls {
+		if s.mergeLayers {
+			doc.Totals.Pulled += credit[p]
+		} else {
+			doc.Totals.Pulled += p.transferred
+		}
+		img := summaryImage{
+			Image:         p.image,
+			Result:        p.reportResult(),
+			ID:            p.imageID,
+			Pulled:        p.transferred,
+			Reused:        p.reused,
+			Size:          p.size,
+			PeakDownloads: p.concurrency.peak,
+			AvgDownloads:  p.concurrency.average(),
+			Reason:        p.cancelReason.String(),
+		}
+		if p.started && !p.endedAt.IsZero() {
+			img.Duration = p.endedAt.Sub(p.startedAt).Seconds()
+		}
+		if p.err != nil {
+			img.Error = p.err.Error()
+		}
+		doc.Images = append(doc.Images, img)
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.path, append(b, '\n'), 0o644)
+	}
+	if err != nil {
+		errorf("summary: %v", err)
+	}
+}
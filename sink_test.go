@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"dockerpulltui/pull"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// decodeEvents returns the last jsonEvent written per image.
+func decodeEvents(t *testing.T, b []byte) map[string]jsonEvent {
+	t.Helper()
+	last := map[string]jsonEvent{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var ev jsonEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		last[ev.Image] = ev
+	}
+	return last
+}
+
+func TestJSONSinkCancelled(t *testing.T) {
+	var buf bytes.Buffer
+	s := newJSONSink(&buf, "run-1")
+	summary := summarySink{path: filepath.Join(t.TempDir(), "summary.json"), runID: "run-1"}
+	opts := options{parallel: 1, sinks: sinks{s, summary}}
+	// the first pull runs, the second is queued behind --parallel 1
+	m := initialModel([]string{"node:20", "redis:7"}, opts)
+	m.pulls[0].started = true
+	m.pulls[0].startedAt = now()
+	var tm tea.Model = m
+	tm, _ = tm.Update(progressEvent{idx: 0, Event: pull.Event{ID: "a", Status: "Downloading", Current: 1 << 20, Total: 4 << 20, HasCounts: true}})
+	tm, _ = tm.Update(cancelMsg{cancelSignal})
+	// the cancelled stream closes its channel, which reads as pullDone
+	tm = endPull(tm, 0)
+	pulls := tm.(model).pulls
+	opts.sinks.end(pulls)
+
+	last := decodeEvents(t, buf.Bytes())
+	for _, img := range []string{"node:20", "redis:7"} {
+		ev, ok := last[img]
+		if !ok {
+			t.Fatalf("no event for %s", img)
+		}
+		if ev.Status != "cancelled" || ev.Done == nil || *ev.Done || ev.Cancelled == nil || !*ev.Cancelled {
+			t.Errorf("%s: last event = %+v, want a cancelled terminal record", img, ev)
+		}
+		if ev.Reason != "signal" {
+			t.Errorf("%s: reason = %q, want %q", img, ev.Reason, "signal")
+		}
+		if ev.RunID != "run-1" {
+			t.Errorf("%s: runID = %q, want %q", img, ev.RunID, "run-1")
+		}
+	}
+	if pct := last["node:20"].OverallPct; pct != 25 {
+		t.Errorf("overallPct = %v, want the 25 it reached", pct)
+	}
+	b, err := os.ReadFile(summary.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		RunID  string         `json:"runID"`
+		Images []summaryImage `json:"images"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.RunID != "run-1" {
+		t.Errorf("summary runID = %q, want %q", doc.RunID, "run-1")
+	}
+	for _, img := range doc.Images {
+		if img.Result != "cancelled" || img.Reason != "signal" {
+			t.Errorf("%s: summary result, reason = %q, %q, want cancelled by signal", img.Image, img.Result, img.Reason)
+		}
+	}
+	if got := exitCode(pulls); got != exitCancelled {
+		t.Errorf("exitCode() = %d, want %d", got, exitCancelled)
+	}
+}
+
+// TestJSONSinkImageStatus replays a pull into --format json and checks that
+// the image-level statuses of the stream are events of their own, each with
+// its timestamp.
+func TestJSONSinkImageStatus(t *testing.T) {
+	var buf bytes.Buffer
+	replayEach(t, "pull-by-digest.json", "node:20", options{sinks: sinks{newJSONSink(&buf, "")}}, nil)
+	var messages []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var raw map[string]any
+		if err := dec.Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := raw["timestamp"].(string); !ok {
+			t.Fatalf("event %v has no timestamp", raw)
+		}
+		if msg, _ := raw["message"].(string); msg != "" && !slices.Contains(messages, msg) {
+			messages = append(messages, msg)
+		}
+	}
+	want := []string{
+		"Digest: sha256:4a1b7c3e9f0d2b6a8c5e7f1a3b9d0c2e4f6a8b1c3d5e7f9a0b2c4d6e8f1a3b5c",
+		"Status: Downloaded newer image for node@sha256:4a1b7c3e9f0d2b6a8c5e7f1a3b9d0c2e4f6a8b1c3d5e7f9a0b2c4d6e8f1a3b5c",
+	}
+	if !slices.Equal(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// socketWriteTimeout bounds how long a slow --progress-socket reader may hold
+// up the UI on one message.
+const socketWriteTimeout = 100 * time.Millisecond
+
+// socketWriter streams progress to a Unix socket without ever failing the
+// pull: once a write fails or times out, the connection is dropped and
+// further progress discarded.
+type socketWriter struct {
+	conn net.Conn
+}
+
+// dialProgressSocket connects to the listener at path.
+func dialProgressSocket(path string) (*socketWriter, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &socketWriter{conn: conn}, nil
+}
+
+// Write sends b and always succeeds.
+func (w *socketWriter) Write(b []byte) (int, error) {
+	if w.conn == nil {
+		return len(b), nil
+	}
+	_ = w.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	if _, err := w.conn.Write(b); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: progress socket: %v; no longer streaming\n", err)
+		w.conn.Close()
+		w.conn = nil
+	}
+	return len(b), nil
+}
+
+func (w *socketWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
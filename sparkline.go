@@ -0,0 +1,25 @@
+package main
+
+import "strings"
+
+// sparklineSamples is how many recent rate samples --sparkline shows, one
+// column each.
+const sparklineSamples = 16
+
+// sparkline renders rates, oldest first, as one of levels per sample scaled
+// to the largest of them.
+func sparkline(rates []float64, levels []rune) string {
+	var peak float64
+	for _, r := range rates {
+		peak = max(peak, r)
+	}
+	var b strings.Builder
+	for _, r := range rates {
+		i := 0
+		if peak > 0 {
+			i = int(r / peak * float64(len(levels)-1))
+		}
+		b.WriteRune(levels[min(max(i, 0), len(levels)-1)])
+	}
+	return b.String()
+}
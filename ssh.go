@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// sshDummyHost is the HTTP host used for requests tunnelled over ssh; the
+// dialer ignores it.
+const sshDummyHost = "http://docker.example.com"
+
+// sshClientOptions returns client options reaching the daemon at an
+// ssh://[user@]host[:port] URL through `docker system dial-stdio` on the
+// remote host, the same way the docker CLI does.
+func sshClientOptions(host string) ([]client.Opt, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh host %q: %w", host, err)
+	}
+	if u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("invalid ssh host %q (want ssh://[user@]host[:port])", host)
+	}
+	args := []string{"-o", "ConnectTimeout=30"}
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialCommand(ctx, u.Host, "ssh", args...)
+	}
+	return []client.Opt{client.WithHost(sshDummyHost), client.WithDialContext(dial)}, nil
+}
+
+// sshError reports that the ssh tunnel to the daemon failed, as opposed to
+// the daemon or registry rejecting the pull.
+type sshError struct {
+	host   string
+	detail string
+}
+
+func (e *sshError) Error() string {
+	return fmt.Sprintf("ssh connection to %s failed: %s", e.host, e.detail)
+}
+
+// cmdConn is a net.Conn over the stdin and stdout of a command.
+type cmdConn struct {
+	host   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	// waitOnce reaps the command once, on the end of its output or on
+	// Close, keeping the result in waitErr
+	waitOnce  sync.Once
+	waitErr   error
+	closeOnce sync.Once
+}
+
+func dialCommand(ctx context.Context, host, name string, args ...string) (net.Conn, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &cmdConn{host: host, cmd: cmd, stdin: stdin, stdout: stdout, stderr: &bytes.Buffer{}}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, &sshError{host: host, detail: err.Error()}
+	}
+	return c, nil
+}
+
+// wait waits for the command to exit, once, and returns how it exited.
+func (c *cmdConn) wait() error {
+	c.waitOnce.Do(func() { c.waitErr = c.cmd.Wait() })
+	return c.waitErr
+}
+
+func (c *cmdConn) Read(b []byte) (int, error) {
+	n, err := c.stdout.Read(b)
+	// once reaped, the pipe is closed rather than at EOF
+	if n == 0 && (err == io.EOF || errors.Is(err, os.ErrClosed)) {
+		err = io.EOF
+		// the tunnel closed; if ssh complained, that is the real error
+		if werr := c.wait(); werr != nil {
+			detail := strings.TrimSpace(c.stderr.String())
+			if detail == "" {
+				detail = werr.Error()
+			}
+			return 0, &sshError{host: c.host, detail: detail}
+		}
+	}
+	return n, err
+}
+
+func (c *cmdConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *cmdConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stdin.Close()
+		_ = c.cmd.Process.Kill()
+		c.wait()
+	})
+	return nil
+}
+
+func (c *cmdConn) LocalAddr() net.Addr  { return dummyAddr{} }
+func (c *cmdConn) RemoteAddr() net.Addr { return dummyAddr{} }
+
+func (c *cmdConn) SetDeadline(time.Time) error      { return nil }
+func (c *cmdConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *cmdConn) SetWriteDeadline(time.Time) error { return nil }
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string { return "dummy" }
+func (dummyAddr) String() string  { return "dummy" }
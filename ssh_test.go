@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestCmdConnEnd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the tunnel is a shell command")
+	}
+	tests := []struct {
+		name   string
+		script string
+		// detail is the sshError of a failed tunnel, or "" for a clean end
+		detail string
+	}{
+		{"clean exit", "printf hello", ""},
+		{"ssh complains", "printf hello; echo 'Permission denied (publickey).' >&2; exit 255", "Permission denied (publickey)."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := dialCommand(context.Background(), "example.com", "sh", "-c", tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			b, err := io.ReadAll(conn)
+			if string(b) != "hello" {
+				t.Errorf("read %q, want %q", b, "hello")
+			}
+			// reading on after the end, the command already reaped, gives
+			// the same answer
+			_, again := conn.Read(make([]byte, 1))
+			if tt.detail == "" {
+				if err != nil || again != io.EOF {
+					t.Errorf("errors = %v, then %v, want a clean end, then EOF", err, again)
+				}
+				return
+			}
+			for _, err := range []error{err, again} {
+				var sshErr *sshError
+				if !errors.As(err, &sshErr) || sshErr.detail != tt.detail {
+					t.Errorf("err = %v, want the ssh error %q", err, tt.detail)
+				}
+			}
+		})
+	}
+}
+
+// TestCmdConnCloseReaps checks that closing a tunnel whose command still
+// runs kills it and waits for it, so it is not left a zombie.
+func TestCmdConnCloseReaps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the tunnel is a shell command")
+	}
+	conn, err := dialCommand(context.Background(), "example.com", "sleep", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := conn.(*cmdConn)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c.cmd.ProcessState == nil {
+		t.Error("the command was not waited for after Close")
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import "dockerpulltui/pull"
+
+// statusText holds the words shown when a pull ends, so they can be
+// localized or rebranded. Empty fields fall back to defaultStatusText.
+type statusText struct {
+	Done      string
+	UpToDate  string
+	Present   string
+	Cancelled string
+	Failed    string
+}
+
+var defaultStatusText = statusText{
+	Done:      "DONE",
+	UpToDate:  "UP TO DATE",
+	Present:   "already present",
+	Cancelled: "CANCELLED",
+	Failed:    "FAILED",
+}
+
+// withDefaults returns st with every empty field set to its default.
+func (st statusText) withDefaults() statusText {
+	def := defaultStatusText
+	if st.Done == "" {
+		st.Done = def.Done
+	}
+	if st.UpToDate == "" {
+		st.UpToDate = def.UpToDate
+	}
+	if st.Present == "" {
+		st.Present = def.Present
+	}
+	if st.Cancelled == "" {
+		st.Cancelled = def.Cancelled
+	}
+	if st.Failed == "" {
+		st.Failed = def.Failed
+	}
+	return st
+}
+
+// compactLayerStatus maps a layer status from the pull stream to one of a few
+// calm buckets: transient states such as "Waiting", "Pulling fs layer" and
+// "Retrying in 5 seconds" all become "preparing", while downloading,
+// extracting and completion stay distinct. Unknown statuses pass through.
+func compactLayerStatus(status string, ph pull.Phase) string {
+	switch ph {
+	case pull.PhaseWaiting, pull.PhaseRetrying:
+		return "preparing"
+	case pull.PhaseDownloading:
+		return "downloading"
+	case pull.PhaseVerifying, pull.PhaseDownloaded:
+		return "downloaded"
+	case pull.PhaseExtracting:
+		return "extracting"
+	case pull.PhaseComplete, pull.PhaseExists:
+		return "complete"
+	}
+	return status
+}
+
+// layerStatus names a layer's status for display, through
+// compactLayerStatus under --compact-status.
+func (o options) layerStatus(l layerState) string {
+	if o.compactStatus {
+		return compactLayerStatus(l.status, l.phase)
+	}
+	return l.status
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"dockerpulltui/pull"
+)
+
+func TestLayerStatus(t *testing.T) {
+	tests := []struct {
+		status  string
+		compact string
+	}{
+		{"Pulling fs layer", "preparing"},
+		{"Waiting", "preparing"},
+		{"Retrying in 5 seconds", "preparing"},
+		{"Downloading", "downloading"},
+		{"Verifying Checksum", "downloaded"},
+		{"Download complete", "downloaded"},
+		{"Extracting", "extracting"},
+		{"Pull complete", "complete"},
+		{"Already exists", "complete"},
+		{"Something new", "Something new"},
+	}
+	for _, tt := range tests {
+		l := layerState{status: tt.status, phase: pull.DockerPhases.Classify(tt.status)}
+		if got := (options{compactStatus: true}).layerStatus(l); got != tt.compact {
+			t.Errorf("compact layerStatus(%q) = %q, want %q", tt.status, got, tt.compact)
+		}
+		if got := (options{}).layerStatus(l); got != tt.status {
+			t.Errorf("layerStatus(%q) = %q, want it unchanged", tt.status, got)
+		}
+	}
+}
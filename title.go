@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// XTWINOPS sequences that save the window title on the terminal's title stack
+// and restore it, so --title-progress can put back the title it replaced.
+// Terminals without a title stack ignore them.
+const (
+	pushTitleSeq = "\x1b[22;0t"
+	popTitleSeq  = "\x1b[23;0t"
+)
+
+// titleModel wraps model for --title-progress, mirroring the overall
+// percentage in the terminal's window title, e.g. "42% node:20", whenever it
+// changes. The in-line bars render as usual.
+type titleModel struct {
+	model
+	// title is the title last set
+	title string
+}
+
+func (tm titleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := tm.model.Update(msg)
+	tm.model = next.(model)
+	if title := tm.progressTitle(); title != tm.title {
+		tm.title = title
+		cmd = tea.Batch(cmd, tea.SetWindowTitle(title))
+	}
+	return tm, cmd
+}
+
+// progressTitle is the window title for the run's current progress.
+func (m model) progressTitle() string {
+	done, _, frac := m.overall()
+	if m.isBatch() {
+		return fmt.Sprintf("%.0f%% %d/%d images", 100*frac, done, len(m.pulls))
+	}
+	return fmt.Sprintf("%.0f%% %s", 100*frac, m.pulls[0].image)
+}
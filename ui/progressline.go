@@ -6,6 +6,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
 )
 
 // SetPercentMsg updates the progress to a value in [0,1].
@@ -14,6 +15,10 @@ type SetPercentMsg struct{ Pct float64 }
 // DoneMsg marks the line as complete (renders 100%).
 type DoneMsg struct{}
 
+// ResetMsg returns the line to 0% so it can be reused for another task.
+// A non-empty Label replaces the current one.
+type ResetMsg struct{ Label string }
+
 // CancelMsg requests cancellation from the parent model (e.g., on Esc).
 type CancelMsg struct{}
 
@@ -27,8 +32,39 @@ type ProgressLine struct {
 	Bar     progress.Model
 	Percent float64
 	Done    bool
+	// LabelWidth, if positive, fixes the label column to this many terminal
+	// cells so the bars of stacked lines line up. Longer labels are truncated.
+	LabelWidth int
+	// Ellipsis ends a label truncated to LabelWidth; empty means "…".
+	Ellipsis string
+	// Heartbeat, if positive, animates a marker after the bar once the
+	// percentage has not changed for this long, so that a stalled line shows
+	// the program is alive. It keeps the animation ticker running despite
+	// IdleAfter until the line is Done.
+	Heartbeat time.Duration
+	// IdleAfter, if positive, stops the animation ticker once the line is Done
+	// or its percentage has not changed for this long; the next SetPercentMsg
+	// restarts it. Zero keeps ticking for as long as the line is updated.
+	IdleAfter time.Duration
+
+	ticking bool
+	changed time.Time
 }
 
+// now is the clock behind IdleAfter; tests may replace it.
+var now = time.Now
+
+// tickInterval is how often the animation ticker fires.
+const tickInterval = 100 * time.Millisecond
+
+// heartbeatFrames are the marker's frames, each shown for heartbeatStep.
+var heartbeatFrames = []string{" .  ", " .. ", " ...", "    "}
+
+const heartbeatStep = 300 * time.Millisecond
+
+// tickMsg drives the animation ticker of one line.
+type tickMsg struct{ p *ProgressLine }
+
 // NewProgressLine creates a new progress line with a label.
 func NewProgressLine(label string) *ProgressLine {
 	pl := &ProgressLine{
@@ -39,9 +75,33 @@ func NewProgressLine(label string) *ProgressLine {
 	return pl
 }
 
-// InitCmd returns a tick command you can use in your parent model to drive animations.
+// InitCmd returns a tick command you can use in your parent model to drive
+// animations. Pass the messages it produces back to Update to keep it going.
 func (p *ProgressLine) InitCmd() tea.Cmd {
-	return tea.Tick(time.Millisecond*100, func(time.Time) tea.Msg { return progress.FrameMsg{} })
+	p.ticking = true
+	p.changed = now()
+	return p.tick()
+}
+
+func (p *ProgressLine) tick() tea.Cmd {
+	return tea.Tick(tickInterval, func(time.Time) tea.Msg { return tickMsg{p} })
+}
+
+// wake restarts a ticker stopped by IdleAfter once there is progress to show.
+func (p *ProgressLine) wake() tea.Cmd {
+	if p.ticking || p.idle() {
+		return nil
+	}
+	p.ticking = true
+	return p.tick()
+}
+
+// idle reports whether the ticker may stop under IdleAfter.
+func (p *ProgressLine) idle() bool {
+	if p.Heartbeat > 0 && !p.Done {
+		return false
+	}
+	return p.IdleAfter > 0 && (p.Done || now().Sub(p.changed) >= p.IdleAfter)
 }
 
 // Update handles Bubble Tea messages for this component.
@@ -67,15 +127,29 @@ func (p *ProgressLine) Update(msg tea.Msg) (tea.Cmd, bool) {
 		if pct < p.Percent {
 			return nil, true
 		}
+		if pct > p.Percent {
+			p.changed = now()
+		}
 		p.Percent = pct
 		if pct >= 1 {
 			p.Done = true
 		}
-		return p.Bar.SetPercent(p.Percent), true
+		return tea.Batch(p.setBar(p.Percent), p.wake()), true
 	case DoneMsg:
 		p.Percent = 1
 		p.Done = true
-		return p.Bar.SetPercent(1), true
+		return p.setBar(1), true
+	case ResetMsg:
+		return p.Reset(m.Label), true
+	case tickMsg:
+		if m.p != p {
+			return nil, false
+		}
+		if p.idle() {
+			p.ticking = false
+			return nil, true
+		}
+		return p.tick(), true
 	case progress.FrameMsg:
 		var cmd tea.Cmd
 		var nxt tea.Model
@@ -88,7 +162,47 @@ func (p *ProgressLine) Update(msg tea.Msg) (tea.Cmd, bool) {
 	return nil, false
 }
 
+// setBar animates the bar towards pct. The frame command of SetPercent reads
+// the bar it was called on once its tick fires, on another goroutine, so it
+// gets a copy rather than Bar, which later updates write to.
+func (p *ProgressLine) setBar(pct float64) tea.Cmd {
+	bar := p.Bar
+	cmd := bar.SetPercent(pct)
+	p.Bar = bar
+	return cmd
+}
+
+// Reset returns the line to 0% and clears Done, keeping the bar's width and
+// style. A non-empty label replaces the current one.
+func (p *ProgressLine) Reset(label string) tea.Cmd {
+	if label != "" {
+		p.Label = label
+	}
+	p.Percent = 0
+	p.Done = false
+	p.changed = now()
+	return tea.Batch(p.setBar(0), p.wake())
+}
+
 // View returns the single-line string for this component.
 func (p *ProgressLine) View() string {
-	return fmt.Sprintf("%s... %s", p.Label, p.Bar.ViewAs(p.Percent))
+	label := p.Label
+	if p.LabelWidth > 0 {
+		tail := p.Ellipsis
+		if tail == "" {
+			tail = "…"
+		}
+		label = runewidth.FillRight(runewidth.Truncate(label, p.LabelWidth, tail), p.LabelWidth)
+	}
+	return fmt.Sprintf("%s... %s%s", label, p.Bar.ViewAs(p.Percent), p.heartbeat())
+}
+
+// heartbeat returns the marker's current frame while the line is stalled,
+// and "" otherwise.
+func (p *ProgressLine) heartbeat() string {
+	stalled := now().Sub(p.changed)
+	if p.Heartbeat <= 0 || p.Done || p.changed.IsZero() || stalled < p.Heartbeat {
+		return ""
+	}
+	return heartbeatFrames[int((stalled-p.Heartbeat)/heartbeatStep)%len(heartbeatFrames)]
 }
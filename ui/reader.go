@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// minReportStep is the least percentage change CountingReader reports, so a
+// fast copy does not flood the program with messages.
+const minReportStep = 0.005
+
+// CountingReader wraps an io.Reader of known total size and reports how much
+// of it has been read as SetPercentMsg values passed to send, typically
+// (*tea.Program).Send. Forward those messages to a ProgressLine to show a copy
+// or download of any kind.
+type CountingReader struct {
+	r       io.Reader
+	total   int64
+	n       int64
+	send    func(tea.Msg)
+	lastPct float64
+}
+
+// NewCountingReader returns a CountingReader reading from r, which is
+// expected to yield total bytes.
+func NewCountingReader(r io.Reader, total int64, send func(tea.Msg)) *CountingReader {
+	return &CountingReader{r: r, total: total, send: send}
+}
+
+// Read reads from the underlying reader and reports progress.
+func (c *CountingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	if c.total > 0 {
+		pct := float64(c.n) / float64(c.total)
+		if pct-c.lastPct >= minReportStep || (err == io.EOF && pct > c.lastPct) {
+			c.lastPct = pct
+			c.send(SetPercentMsg{Pct: pct})
+		}
+	}
+	return n, err
+}
+
+// N returns the number of bytes read so far.
+func (c *CountingReader) N() int64 {
+	return c.n
+}
+
+// Reader wraps an io.Reader of known total size and moves a ProgressLine as
+// it is read. It updates the line directly, so it is meant for use outside a
+// Bubble Tea program, e.g. in a plain CLI; inside one, use CountingReader and
+// let the program deliver the messages.
+type Reader struct {
+	*CountingReader
+	pl *ProgressLine
+	// Out, if set, receives the line redrawn in place after each update.
+	Out io.Writer
+}
+
+// NewReader returns a Reader reading from r, which is expected to yield total
+// bytes, and driving pl.
+func NewReader(r io.Reader, total int64, pl *ProgressLine) *Reader {
+	rd := &Reader{pl: pl}
+	rd.CountingReader = NewCountingReader(r, total, rd.update)
+	return rd
+}
+
+func (r *Reader) update(msg tea.Msg) {
+	_, _ = r.pl.Update(msg)
+	if r.Out != nil {
+		fmt.Fprintf(r.Out, "\r%s", r.pl.View())
+	}
+}
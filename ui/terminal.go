@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// Cursor sequences written around in-place drawing.
+const (
+	hideCursor = "\x1b[?25l"
+	showCursor = "\x1b[?25h"
+)
+
+// RestoreTerminal shows the cursor on out. It undoes what an interrupted
+// in-place renderer may leave behind and is harmless on a terminal that
+// needs no restoring.
+func RestoreTerminal(out io.Writer) error {
+	_, err := io.WriteString(out, showCursor)
+	return err
+}
+
+// PrepareTerminal saves the state of the terminal f and hides its cursor, for
+// drawing progress in place outside a Bubble Tea program, e.g. with a Reader.
+// The returned cleanup restores the saved state and shows the cursor; it is
+// meant for a defer and safe to call more than once. When f is not a terminal
+// nothing changes and cleanup does nothing.
+func PrepareTerminal(f *os.File) (cleanup func()) {
+	if !term.IsTerminal(f.Fd()) {
+		return func() {}
+	}
+	state, err := term.GetState(f.Fd())
+	if err != nil {
+		return func() {}
+	}
+	_, _ = io.WriteString(f, hideCursor)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			_ = term.Restore(f.Fd(), state)
+			_ = RestoreTerminal(f)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watchPulls calls run every interval until a run is cancelled or the process
+// is interrupted between runs. After each run it logs one line per image
+// telling whether new content was fetched.
+func watchPulls(interval time.Duration, out, errOut io.Writer, run func() model) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		m := run()
+		reportErrors(errOut, m.pulls)
+		at := now().Format(time.TimeOnly)
+		for _, p := range m.pulls {
+			if p.started {
+				fmt.Fprintf(out, "[%s] %s: %s\n", at, p.image, p.watchResult())
+			}
+		}
+		if m.cancelReason != notCancelled {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// watchResult is the compact --watch log entry for an ended pull.
+func (p *imagePull) watchResult() string {
+	switch {
+	case p.cancelReason != notCancelled:
+		return "cancelled"
+	case p.err != nil:
+		return "failed"
+	case p.present:
+		return "present"
+	case p.hideBar || !p.sawDownload:
+		return "up to date"
+	case p.digest != "":
+		return "fetched " + p.digest
+	}
+	return "fetched new content"
+}
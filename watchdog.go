@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// killGrace is how long pulls cancelled by the --max-runtime watchdog get to
+// wind down before the program is killed.
+const killGrace = 5 * time.Second
+
+// startWatchdog bounds the run of prog to limit. Once it is exceeded the
+// pulls are cancelled like any other cancellation, closing their streams. If
+// that does not end the run, say because a read is stuck, the program is
+// killed, which restores the terminal and returns from its Run with the
+// pulls still marked as timed out. The returned stop disarms the watchdog.
+func startWatchdog(prog *tea.Program, limit time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-time.After(limit):
+		}
+		prog.Send(cancelMsg{cancelTimeout})
+		select {
+		case <-done:
+		case <-time.After(killGrace):
+			prog.Kill()
+		}
+	}()
+	return func() { close(done) }
+}